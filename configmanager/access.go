@@ -0,0 +1,154 @@
+package configmanager
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Get returns value found by dotted key path (e.g. "server.tls.cert").
+// Keys are matched against serialization tags of T case insensitively.
+func (m *Manager[T]) Get(keyPath string) (any, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.get(m.config, keyPath)
+}
+
+// GetRedacted returns value found by dotted key path like Get, with secret
+// fields redacted
+func (m *Manager[T]) GetRedacted(keyPath string) (any, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cfg := cloneConfig(m.config)
+	redactSecrets(cfg)
+	return m.get(cfg, keyPath)
+}
+
+// get returns value of cfg found by dotted key path
+func (m *Manager[T]) get(cfg *T, keyPath string) (any, error) {
+	keys, err := splitKeyPath(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	v, err := getPath(reflect.ValueOf(cfg).Elem(), keys, m.format)
+	if err != nil {
+		return nil, fmt.Errorf("get %v: %v", keyPath, err)
+	}
+	return v.Interface(), nil
+}
+
+// Set assigns value found by dotted key path. String values are converted to
+// the field type. New configuration is validated before it replaces current one.
+func (m *Manager[T]) Set(keyPath string, value any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cfg, base, owned, err := m.withValue(keyPath, value)
+	if err != nil {
+		return err
+	}
+	m.config, m.base, m.owned = cfg, base, owned
+	return nil
+}
+
+// SetAndSave assigns value found by dotted key path and saves configuration to disk.
+// Current configuration is kept intact if save fails.
+func (m *Manager[T]) SetAndSave(keyPath string, value any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	cfg, base, owned, err := m.withValue(keyPath, value)
+	if err != nil {
+		return err
+	}
+	m.stampVersion(base)
+	if err := m.write(base, owned); err != nil {
+		return err
+	}
+	m.config, m.base, m.owned = cfg, base, owned
+	return nil
+}
+
+// withValue returns validated copy of current config with value set by key
+// path, along with user layer and its keys changed the same way
+func (m *Manager[T]) withValue(keyPath string, value any) (*T, *T, keyTree, error) {
+	keys, err := splitKeyPath(keyPath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cfg := cloneConfig(m.config)
+	if err := setPath(reflect.ValueOf(cfg).Elem(), keys, value, m.format); err != nil {
+		return nil, nil, nil, fmt.Errorf("set %v: %v", keyPath, err)
+	}
+	if err := m.validate(cfg); err != nil {
+		return nil, nil, nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	base, owned := cloneConfig(m.base), m.owned.clone()
+	if err := m.changeUserKey(base, owned, cfg, keys); err != nil {
+		return nil, nil, nil, fmt.Errorf("set %v: %v", keyPath, err)
+	}
+	return cfg, base, owned, nil
+}
+
+// changeUserKey copies value of key path from src to user layer base and adds
+// the key path to owned
+func (m *Manager[T]) changeUserKey(base *T, owned keyTree, src *T, keys []string) error {
+	value, err := getPath(reflect.ValueOf(src).Elem(), keys, m.format)
+	if err != nil {
+		return err
+	}
+	if err := setPath(reflect.ValueOf(base).Elem(), keys, cloneValue(value).Interface(), m.format); err != nil {
+		return err
+	}
+	owned.add(canonicalKeys(reflect.TypeOf(base).Elem(), keys, m.format))
+	return nil
+}
+
+// Reset restores default values of given key paths and saves them to the user
+// file. Without key paths the user file is reset to defaults and configuration
+// is loaded again, so values of other files, environment and overrides apply.
+func (m *Manager[T]) Reset(keyPaths ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if len(keyPaths) == 0 {
+		base := cloneConfig(m.defaults)
+		m.stampVersion(base)
+		if err := m.write(base, keyTree{}); err != nil {
+			return err
+		}
+		return m.load()
+	}
+	cfg, base, owned := cloneConfig(m.config), cloneConfig(m.base), m.owned.clone()
+	for _, keyPath := range keyPaths {
+		keys, err := splitKeyPath(keyPath)
+		if err != nil {
+			return err
+		}
+		def, err := getPath(reflect.ValueOf(m.defaults).Elem(), keys, m.format)
+		if err != nil {
+			return fmt.Errorf("reset %v: %v", keyPath, err)
+		}
+		if err := setPath(reflect.ValueOf(cfg).Elem(), keys, cloneValue(def).Interface(), m.format); err != nil {
+			return fmt.Errorf("reset %v: %v", keyPath, err)
+		}
+		if err := m.changeUserKey(base, owned, cfg, keys); err != nil {
+			return fmt.Errorf("reset %v: %v", keyPath, err)
+		}
+	}
+	if err := m.validate(cfg); err != nil {
+		return fmt.Errorf("config validation failed: %w", err)
+	}
+	m.stampVersion(base)
+	if err := m.write(base, owned); err != nil {
+		return err
+	}
+	m.config, m.base, m.owned = cfg, base, owned
+	return nil
+}
@@ -0,0 +1,172 @@
+package configmanager
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type accessConfig struct {
+	Name   string            `toml:"name"`
+	Labels map[string]string `toml:"labels"`
+	Server struct {
+		Port    int           `toml:"port"`
+		Timeout time.Duration `toml:"timeout"`
+		TLS     *struct {
+			Cert string `toml:"cert"`
+		} `toml:"tls"`
+	} `toml:"server"`
+}
+
+func TestManager_GetSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	m, err := New("testapp", accessConfig{Name: "app"}, ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	tests := []struct {
+		key     string
+		value   any
+		want    any
+		wantErr bool
+	}{
+		{key: "name", value: "new", want: "new"},
+		{key: "server.port", value: "8080", want: 8080},
+		{key: "Server.Port", value: 9090, want: 9090},
+		{key: "server.timeout", value: "1m", want: time.Minute},
+		{key: "server.tls.cert", value: "/etc/cert.pem", want: "/etc/cert.pem"},
+		{key: "labels.env", value: "prod", want: "prod"},
+		{key: "server.port", value: "not a number", wantErr: true},
+		{key: "server.unknown", value: "1", wantErr: true},
+		{key: "name.sub", value: "1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			err := m.Set(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := m.Get(tt.key)
+			if err != nil {
+				t.Fatalf("Get() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Get() = %v (%T), want %v (%T)", got, got, tt.want, tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_SetValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	m, err := New("testapp", validatedConfig{Port: 1}, ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Set("port", "-1"); err == nil {
+		t.Error("Set() succeeded unexpectedly")
+	}
+	if got := m.Config().Port; got != 1 {
+		t.Errorf("Config().Port = %v, want 1", got)
+	}
+	if err := m.SetAndSave("port", "42"); err != nil {
+		t.Fatalf("SetAndSave() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config().Port; got != 42 {
+		t.Errorf("Config().Port = %v, want 42", got)
+	}
+}
+
+func TestManager_Reset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	for key, value := range map[string]string{"name": "changed", "server.port": "1", "tags": "x,y"} {
+		if err := m.Set(key, value); err != nil {
+			t.Fatalf("Set() failed: %v", err)
+		}
+	}
+	if err := m.Reset("server.port", "tags"); err != nil {
+		t.Fatalf("Reset() failed: %v", err)
+	}
+	got := m.Config()
+	if got.Name != "changed" || got.Server.Port != 8080 || !reflect.DeepEqual(got.Tags, []string{"a"}) {
+		t.Errorf("Config() after key reset = %+v", got)
+	}
+	if err := m.Reset("server.unknown"); err == nil {
+		t.Error("Reset() of unknown key succeeded unexpectedly")
+	}
+
+	if err := m.Reset(); err != nil {
+		t.Fatalf("Reset() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config(); !reflect.DeepEqual(got, defaultTestConfig()) {
+		t.Errorf("Config() after reset = %+v, want defaults", got)
+	}
+}
+
+type EmbeddedServer struct {
+	Host string `toml:"host" yaml:"host" json:"host"`
+	Port int    `toml:"port" yaml:"port" json:"port"`
+}
+
+type EmbeddedLimits struct {
+	Max int `toml:"max" yaml:"max" json:"max"`
+}
+
+type embeddedConfig struct {
+	EmbeddedServer  `yaml:",inline"`
+	*EmbeddedLimits `yaml:",inline"`
+	Name            string `toml:"name" yaml:"name" json:"name"`
+}
+
+func TestManager_EmbeddedKeys(t *testing.T) {
+	for _, format := range []SerializationFormat{TOML, YAML, JSON, INI} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config."+string(format))
+			m, err := New("testapp", embeddedConfig{}, ForcePath(path), WithSerializationFormat(format))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if _, err := m.Get("max"); err == nil {
+				t.Error("Get() of key in nil embedded struct succeeded")
+			}
+			set := map[string]any{"host": "example.com", "max": 5, "port": 8080}
+			for key, value := range set {
+				if err := m.Set(key, value); err != nil {
+					t.Fatalf("Set(%v) failed: %v", key, err)
+				}
+			}
+			if err := m.Save(); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+
+			loaded, err := New("testapp", embeddedConfig{}, ForcePath(path), WithSerializationFormat(format),
+				WithUnknownKeys(UnknownKeysReject))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := loaded.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			for key, want := range set {
+				if got, err := loaded.Get(key); err != nil || got != want {
+					t.Errorf("Get(%v) = %v, %v, want %v", key, got, err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package configmanager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// AuditEntry records changes written to config file by a single save
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Path    string    `json:"path"`
+	Changes []Change  `json:"changes"`
+}
+
+// WithAuditLog option appends changes made by every save to audit log.
+// Log is stored as audit.log in the application XDG state directory
+// unless WithAuditLogFile is used. Secret values are redacted.
+func WithAuditLog() ManagerOption {
+	return func(mo *managerOptions) {
+		mo.audit = true
+	}
+}
+
+// WithAuditLogFile option sets path to audit log
+func WithAuditLogFile(path string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.auditPath = path
+	}
+}
+
+// AuditLog returns entries of audit log, oldest first
+func (m *Manager[T]) AuditLog() ([]AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	data, err := m.fs.ReadFile(m.auditPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []AuditEntry{}, nil
+		}
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	entries := []AuditEntry{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		entry := AuditEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("audit log line %v: %v", n, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	return entries, nil
+}
+
+// auditChanges returns redacted changes between config file on disk and data
+// which is about to replace it. Missing or undecodable file counts as empty.
+func (m *Manager[T]) auditChanges(data []byte) ([]Change, error) {
+	if !m.audit {
+		return nil, nil
+	}
+	oldDoc := make(map[string]any)
+	if original, err := m.fs.ReadFile(m.path); err == nil {
+		if err := decode(m.layerFormat(m.path, original), original, &oldDoc); err != nil {
+			oldDoc = make(map[string]any)
+		}
+	}
+	newDoc := make(map[string]any)
+	if err := decode(m.format, data, &newDoc); err != nil {
+		return nil, fmt.Errorf("failed to decode saved config: %v", err)
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	changes := []Change{}
+	for _, change := range diffDocs(oldDoc, newDoc, nil) {
+		changes = append(changes, Change{
+			Key: strings.Join(change.keys, "."),
+			Old: redactKey(t, change.keys, change.old, m.format),
+			New: redactKey(t, change.keys, change.value, m.format),
+		})
+	}
+	return changes, nil
+}
+
+// appendAudit appends entry with changes to audit log
+func (m *Manager[T]) appendAudit(changes []Change) error {
+	if !m.audit || len(changes) == 0 {
+		return nil
+	}
+	entry := AuditEntry{
+		Time:    time.Now().UTC(),
+		User:    currentUser(),
+		Path:    m.path,
+		Changes: changes,
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %v", err)
+	}
+	if err := m.fs.MkdirAll(filepath.Dir(m.auditPath), 0700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+	if err := appendFile(m.fs, m.auditPath, append(line, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return nil
+}
+
+// redactKey redacts document value found at key path of config type t
+func redactKey(t reflect.Type, keys []string, v any, format SerializationFormat) any {
+	for _, key := range keys {
+		var secret bool
+		if t, secret = docChild(t, key, format); secret {
+			return redactSecret(v)
+		}
+	}
+	return redactDoc(t, v, format)
+}
+
+// redactDoc replaces values of secret fields of type t and encrypted values
+// found in document value with RedactedValue. Nil t means type is unknown.
+func redactDoc(t reflect.Type, v any, format SerializationFormat) any {
+	switch value := v.(type) {
+	case string:
+		if strings.HasPrefix(value, secretPrefix) {
+			return RedactedValue
+		}
+	case map[string]any:
+		redacted := make(map[string]any, len(value))
+		for key, item := range value {
+			child, secret := docChild(t, key, format)
+			if secret {
+				redacted[key] = redactSecret(item)
+				continue
+			}
+			redacted[key] = redactDoc(child, item, format)
+		}
+		return redacted
+	case []any:
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		var elem reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elem = t.Elem()
+		}
+		redacted := make([]any, len(value))
+		for i, item := range value {
+			redacted[i] = redactDoc(elem, item, format)
+		}
+		return redacted
+	}
+	return v
+}
+
+// redactSecret returns RedactedValue for non empty secret value
+func redactSecret(v any) any {
+	if v == nil || v == "" {
+		return v
+	}
+	return RedactedValue
+}
+
+// docChild returns type of document key below type t and whether the key is
+// a secret field. Nil type is returned for unknown keys.
+func docChild(t reflect.Type, key string, format SerializationFormat) (reflect.Type, bool) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil, false
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		index, ok := findField(t, key, format)
+		if !ok {
+			return nil, false
+		}
+		field := t.FieldByIndex(index)
+		return field.Type, field.Tag.Get("secret") == "true"
+	case reflect.Map:
+		return t.Elem(), false
+	}
+	return nil, false
+}
+
+// currentUser returns name of user running the process
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	for _, name := range []string{"USER", "USERNAME"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,156 @@
+package configmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.toml")
+	auditPath := filepath.Join(tmpDir, "state", "audit.log")
+	cfg := secretConfig{User: "admin", Token: "s3cr3t-token"}
+
+	m, err := New("testapp", cfg, ForcePath(path),
+		WithKeyFile(filepath.Join(tmpDir, "secret.key")),
+		WithAuditLog(),
+		WithAuditLogFile(auditPath),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("unchanged Save() failed: %v", err)
+	}
+	if err := m.Set("user", "root"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := m.Set("token", "n3w-token"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	for _, plain := range []string{"s3cr3t-token", "n3w-token", secretPrefix} {
+		if strings.Contains(string(data), plain) {
+			t.Errorf("audit log contains secret %q:\n%s", plain, data)
+		}
+	}
+
+	entries, err := m.AuditLog()
+	if err != nil {
+		t.Fatalf("AuditLog() failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("AuditLog() returned %v entries, want 2:\n%s", len(entries), data)
+	}
+	for _, entry := range entries {
+		if entry.User == "" || entry.Time.IsZero() || entry.Path != path {
+			t.Errorf("entry = %+v, want user, time and path", entry)
+		}
+	}
+	want := []Change{
+		{Key: "token", Old: RedactedValue, New: RedactedValue},
+		{Key: "user", Old: "admin", New: "root"},
+	}
+	if got := entries[1].Changes; !reflect.DeepEqual(got, want) {
+		t.Errorf("Changes = %+v, want %+v", got, want)
+	}
+}
+
+func TestAuditLog_Disabled(t *testing.T) {
+	tmpDir := t.TempDir()
+	auditPath := filepath.Join(tmpDir, "audit.log")
+	m, err := New("testapp", defaultTestConfig(), ForcePath(filepath.Join(tmpDir, "config.toml")), WithAuditLogFile(auditPath))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if _, err := os.Stat(auditPath); !os.IsNotExist(err) {
+		t.Errorf("audit log was written without WithAuditLog: %v", err)
+	}
+}
+
+func TestAuditLog_HandEditedSecret(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.toml")
+	auditPath := filepath.Join(tmpDir, "audit.log")
+	writeTestFile(t, path, "user = \"admin\"\ntoken = \"hunter2\"\n[api]\nkey = \"plain-key\"\n")
+
+	m, err := New("testapp", secretConfig{}, ForcePath(path),
+		WithKeyFile(filepath.Join(tmpDir, "secret.key")),
+		WithAuditLog(),
+		WithAuditLogFile(auditPath),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if err := m.SetAndSave("user", "root"); err != nil {
+		t.Fatalf("SetAndSave() failed: %v", err)
+	}
+	data, err := os.ReadFile(auditPath)
+	if err != nil {
+		t.Fatalf("failed to read audit log: %v", err)
+	}
+	for _, plain := range []string{"hunter2", "plain-key"} {
+		if strings.Contains(string(data), plain) {
+			t.Errorf("audit log contains secret %q:\n%s", plain, data)
+		}
+	}
+}
+
+func TestAuditLog_SharedLog(t *testing.T) {
+	tmpDir := t.TempDir()
+	auditPath := filepath.Join(tmpDir, "audit.log")
+	const saves = 20
+	managers := []*Manager[testConfig]{}
+	for _, name := range []string{"ui", "network"} {
+		m, err := New("testapp", defaultTestConfig(), ForcePath(filepath.Join(tmpDir, name+".toml")),
+			WithAuditLog(), WithAuditLogFile(auditPath))
+		if err != nil {
+			t.Fatalf("New() failed: %v", err)
+		}
+		managers = append(managers, m)
+	}
+
+	var wg sync.WaitGroup
+	for _, m := range managers {
+		wg.Add(1)
+		go func(m *Manager[testConfig]) {
+			defer wg.Done()
+			for i := 0; i < saves; i++ {
+				if err := m.SetAndSave("name", fmt.Sprint(i)); err != nil {
+					t.Errorf("SetAndSave() failed: %v", err)
+					return
+				}
+			}
+		}(m)
+	}
+	wg.Wait()
+
+	entries, err := managers[0].AuditLog()
+	if err != nil {
+		t.Fatalf("AuditLog() failed: %v", err)
+	}
+	if len(entries) != saves*len(managers) {
+		t.Errorf("AuditLog() returned %v entries, want %v", len(entries), saves*len(managers))
+	}
+}
@@ -0,0 +1,249 @@
+package configmanager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Galdoba/appcontext/pathspec"
+)
+
+// backupTimeLayout is used to build backup IDs
+const backupTimeLayout = "20060102T150405.000000000Z"
+
+// Backup describes saved previous version of config file
+type Backup struct {
+	ID      string
+	Path    string
+	Created time.Time
+	Size    int64
+}
+
+// Change is a single key difference between two config documents.
+// Old or New is nil if the key is absent in that document.
+type Change struct {
+	Key string `json:"key"`
+	Old any    `json:"old"`
+	New any    `json:"new"`
+}
+
+// WithBackups option keeps up to keep previous versions of config file,
+// created every time the file is replaced. Backups are stored in directory
+// built from pathspec.BackupStorageTemplate unless WithBackupDir is used.
+func WithBackups(keep int) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.backups = keep
+	}
+}
+
+// WithBackupDir option sets directory used to store backups
+func WithBackupDir(dir string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.backupDir = dir
+	}
+}
+
+// defaultBackupDir returns backup directory laid out by pathspec.BackupStorageTemplate
+func defaultBackupDir(appName, fileName string) string {
+	spec := pathspec.NewCustomPath(pathspec.BackupStorageTemplate,
+		pathspec.WithAppName(appName),
+		pathspec.WithName(fileName),
+	)
+	return spec.String()
+}
+
+// ListBackups returns available backups, newest first
+func (m *Manager[T]) ListBackups() ([]Backup, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.listBackups()
+}
+
+// Restore replaces config file with backup and loads it. Current file is
+// backed up once restored config is loaded, and put back if it fails to load.
+func (m *Manager[T]) Restore(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := m.checkConflict(); err != nil {
+		return err
+	}
+	backup, err := m.findBackup(id)
+	if err != nil {
+		return err
+	}
+	data, err := m.fs.ReadFile(backup.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %v", err)
+	}
+	current, readErr := m.fs.ReadFile(m.path)
+	if readErr != nil && !os.IsNotExist(readErr) {
+		return fmt.Errorf("failed to read config file: %v", readErr)
+	}
+	if err := m.fs.WriteFile(m.path, data, m.filePerm()); err != nil {
+		return fmt.Errorf("failed to restore backup: %v", err)
+	}
+	if err := m.load(); err != nil {
+		err = fmt.Errorf("restored config is invalid: %w", err)
+		var putBack error
+		if readErr == nil {
+			putBack = m.fs.WriteFile(m.path, current, m.filePerm())
+		} else {
+			putBack = m.fs.Remove(m.path)
+		}
+		if putBack != nil {
+			return errors.Join(err, fmt.Errorf("failed to put back config file: %v", putBack))
+		}
+		return err
+	}
+	if readErr == nil {
+		return m.keepBackup(current, data)
+	}
+	return nil
+}
+
+// Diff returns changes from backup to current config file
+func (m *Manager[T]) Diff(id string) ([]Change, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	backup, err := m.findBackup(id)
+	if err != nil {
+		return nil, err
+	}
+	old, err := m.readDoc(backup.Path)
+	if err != nil {
+		return nil, err
+	}
+	current, err := m.readDoc(m.path)
+	if err != nil {
+		return nil, err
+	}
+	return docDiff(old, current), nil
+}
+
+// docDiff converts document differences into changes
+func docDiff(old, new map[string]any) []Change {
+	changes := []Change{}
+	for _, change := range diffDocs(old, new, nil) {
+		c := Change{Key: strings.Join(change.keys, "."), Old: change.old}
+		if !change.deleted {
+			c.New = change.value
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// readDoc decodes config file at path into generic document
+func (m *Manager[T]) readDoc(path string) (map[string]any, error) {
+	data, err := m.fs.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	doc := make(map[string]any)
+	if err := decode(m.layerFormat(path, data), data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %v: %v", path, err)
+	}
+	return doc, nil
+}
+
+// backup copies current config file to backup directory if it differs from
+// data which is about to replace it, then removes backups exceeding the limit
+func (m *Manager[T]) backup(data []byte) error {
+	if m.backups <= 0 {
+		return nil
+	}
+	current, err := m.fs.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config for backup: %v", err)
+	}
+	return m.keepBackup(current, data)
+}
+
+// keepBackup saves previous content of config file replaced by data to backup
+// directory, then removes backups exceeding the limit
+func (m *Manager[T]) keepBackup(previous, data []byte) error {
+	if m.backups <= 0 || bytes.Equal(previous, data) {
+		return nil
+	}
+	if err := m.fs.MkdirAll(m.backupDir, 0700); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	id := time.Now().UTC().Format(backupTimeLayout)
+	path := filepath.Join(m.backupDir, id+filepath.Ext(m.path))
+	if err := m.fs.WriteFile(path, previous, m.filePerm()); err != nil {
+		return fmt.Errorf("failed to save backup: %w", err)
+	}
+	backups, err := m.listBackups()
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(len(backups), m.backups):] {
+		if err := m.fs.Remove(old.Path); err != nil {
+			return fmt.Errorf("failed to remove old backup: %v", err)
+		}
+	}
+	return nil
+}
+
+// listBackups returns backups found in backup directory, newest first
+func (m *Manager[T]) listBackups() ([]Backup, error) {
+	entries, err := m.fs.ReadDir(m.backupDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+		return nil, fmt.Errorf("failed to read backup directory: %v", err)
+	}
+	backups := []Backup{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		created, err := time.Parse(backupTimeLayout, id)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			ID:      id,
+			Path:    filepath.Join(m.backupDir, entry.Name()),
+			Created: created,
+			Size:    info.Size(),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+	return backups, nil
+}
+
+// findBackup returns backup with given id
+func (m *Manager[T]) findBackup(id string) (Backup, error) {
+	backups, err := m.listBackups()
+	if err != nil {
+		return Backup{}, err
+	}
+	for _, backup := range backups {
+		if backup.ID == id {
+			return backup, nil
+		}
+	}
+	return Backup{}, fmt.Errorf("backup %v not found", id)
+}
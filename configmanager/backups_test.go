@@ -0,0 +1,86 @@
+package configmanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	backupDir := filepath.Join(dir, "backups")
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithBackups(2), WithBackupDir(backupDir))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	for _, port := range []string{"1", "2", "3", "4"} {
+		if err := m.SetAndSave("server.port", port); err != nil {
+			t.Fatalf("SetAndSave() failed: %v", err)
+		}
+	}
+	backups, err := m.ListBackups()
+	if err != nil {
+		t.Fatalf("ListBackups() failed: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("ListBackups() returned %v backups, want 2", len(backups))
+	}
+	if !backups[0].Created.After(backups[1].Created) {
+		t.Errorf("ListBackups() is not sorted newest first: %+v", backups)
+	}
+
+	changes, err := m.Diff(backups[0].ID)
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Key != "server.port" || changes[0].Old != int64(3) || changes[0].New != int64(4) {
+		t.Errorf("Diff() = %+v, want server.port 3 -> 4", changes)
+	}
+
+	if err := m.Restore(backups[1].ID); err != nil {
+		t.Fatalf("Restore() failed: %v", err)
+	}
+	if got := m.Config().Server.Port; got != 2 {
+		t.Errorf("Config().Server.Port = %v, want 2", got)
+	}
+	if _, err := m.Diff("missing"); err == nil {
+		t.Error("Diff() succeeded unexpectedly")
+	}
+}
+
+func TestRestore_Failures(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	backupDir := filepath.Join(dir, "backups")
+	const id = "20200101T000000.000000000Z"
+	writeTestFile(t, filepath.Join(backupDir, id+".toml"), "[server]\nport = 'x'\n")
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithBackups(1), WithBackupDir(backupDir))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+
+	if err := m.Restore(id); err == nil {
+		t.Fatal("Restore() of invalid backup succeeded")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("invalid restored file was left without previous config file: %v", err)
+	}
+
+	if err := m.SetAndSave("name", "saved"); err != nil {
+		t.Fatalf("SetAndSave() failed: %v", err)
+	}
+	if err := m.Restore(id); err == nil {
+		t.Fatal("Restore() of invalid backup succeeded")
+	}
+	if backups, err := m.ListBackups(); err != nil || len(backups) != 1 || backups[0].ID != id {
+		t.Errorf("ListBackups() = %+v, %v, want only restored backup kept", backups, err)
+	}
+
+	writeTestFile(t, path, "name = \"external\"\n")
+	if err := m.Restore(id); !errors.Is(err, ErrConflict) {
+		t.Errorf("Restore() of externally changed file = %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,72 @@
+package configmanager
+
+import (
+	"fmt"
+	"os"
+)
+
+// WithMergeDefaults option makes LoadOrCreate add keys present in default
+// config but missing in existing config file. User values are never overwritten
+// and keys set by system files are not added.
+func WithMergeDefaults() ManagerOption {
+	return func(mo *managerOptions) {
+		mo.mergeDefaults = true
+	}
+}
+
+// LoadOrCreate writes default config to Path() if file does not exist and loads
+// the configuration. With WithMergeDefaults option keys added to defaults after
+// the file was created are written to existing file.
+func (m *Manager[T]) LoadOrCreate() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.path == "" {
+		return fmt.Errorf("filepath is not set")
+	}
+	unlock, err := m.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if _, err := m.fs.Stat(m.path); err != nil {
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("failed to check config file: %v", err)
+		}
+		defaults := cloneConfig(m.defaults)
+		m.stampVersion(defaults)
+		if err := m.write(defaults, keyTree{}); err != nil {
+			return fmt.Errorf("failed to create default config: %v", err)
+		}
+	}
+	if err := m.load(); err != nil {
+		return err
+	}
+	if m.mergeDefaults {
+		if err := m.mergeDefaultsIntoFile(); err != nil {
+			return fmt.Errorf("failed to merge defaults: %v", err)
+		}
+	}
+	return nil
+}
+
+// mergeDefaultsIntoFile adds missing default keys to user config file. Keys
+// set by system files are left to them.
+func (m *Manager[T]) mergeDefaultsIntoFile() error {
+	data, err := encode(m.format, m.defaults)
+	if err != nil {
+		return err
+	}
+	defaultKeys, err := documentKeys(m.format, data)
+	if err != nil {
+		return err
+	}
+	owned := m.owned.clone()
+	if !owned.merge(defaultKeys.without(m.systemKeys)) {
+		return nil
+	}
+	if err := m.write(m.base, owned); err != nil {
+		return err
+	}
+	m.owned = owned
+	return nil
+}
@@ -0,0 +1,111 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadOrCreate(t *testing.T) {
+	for _, format := range []SerializationFormat{JSON, YAML, TOML} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "nested", "config."+string(format))
+			m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithSerializationFormat(format))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.LoadOrCreate(); err != nil {
+				t.Fatalf("LoadOrCreate() failed: %v", err)
+			}
+			if !fileExists(osFS{}, path) {
+				t.Fatal("LoadOrCreate() did not create config file")
+			}
+			if got := m.Config(); got.Name != "default" || got.Server.Port != 8080 {
+				t.Errorf("Config() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestLoadOrCreate_MergeDefaults(t *testing.T) {
+	for _, format := range []SerializationFormat{JSON, YAML, TOML} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config."+string(format))
+			content := map[SerializationFormat]string{
+				JSON: `{"name": "mine", "server": {"port": 1}}`,
+				YAML: "name: mine\nserver:\n  port: 1\n",
+				TOML: "name = \"mine\"\n[server]\nport = 1\n",
+			}[format]
+			writeTestFile(t, path, content)
+
+			m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithSerializationFormat(format), WithMergeDefaults())
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.LoadOrCreate(); err != nil {
+				t.Fatalf("LoadOrCreate() failed: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read config: %v", err)
+			}
+			for _, want := range []string{"mine", "host", "localhost", "debug"} {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("merged file does not contain %q:\n%s", want, data)
+				}
+			}
+			if got := m.Config(); got.Name != "mine" || got.Server.Port != 1 || got.Server.Host != "localhost" {
+				t.Errorf("Config() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestLoadOrCreate_MergeDefaultsKeepsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	systemPath := filepath.Join(dir, "system.toml")
+	backupDir := filepath.Join(dir, "backups")
+	writeTestFile(t, path, "# my settings\nname = \"mine\" # keep me\n")
+	writeTestFile(t, systemPath, "[server]\nport = 9\n")
+
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path),
+		WithSystemFile(systemPath),
+		WithMergeDefaults(),
+		WithPreserveFormatting(),
+		WithBackups(3),
+		WithBackupDir(backupDir),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.LoadOrCreate(); err != nil {
+		t.Fatalf("LoadOrCreate() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	for _, want := range []string{"# my settings", "# keep me", "debug", "localhost"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("merged file does not contain %q:\n%s", want, data)
+		}
+	}
+	if strings.Contains(string(data), "port") {
+		t.Errorf("merged file contains key of system file:\n%s", data)
+	}
+	if got := m.Config(); got.Name != "mine" || got.Server.Port != 9 {
+		t.Errorf("Config() = %+v", got)
+	}
+	if backups, err := m.ListBackups(); err != nil || len(backups) != 1 {
+		t.Errorf("ListBackups() = %v, %v, want 1 backup", backups, err)
+	}
+
+	if err := m.LoadOrCreate(); err != nil {
+		t.Fatalf("second LoadOrCreate() failed: %v", err)
+	}
+	if again, _ := os.ReadFile(path); string(again) != string(data) {
+		t.Errorf("second merge changed file:\n%s\n%s", data, again)
+	}
+}
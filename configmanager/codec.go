@@ -0,0 +1,326 @@
+package configmanager
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Codec encodes and decodes config files of a serialization format
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// codecEntry is a registered serialization format
+type codecEntry struct {
+	codec Codec
+	// tag is the struct tag holding keys of the format
+	tag string
+	// extension is used to build default config file name
+	extension string
+}
+
+var (
+	codecMu sync.RWMutex
+	codecs  = map[SerializationFormat]codecEntry{
+		JSON:   {codec: jsonCodec{}, tag: "json", extension: ".json"},
+		YAML:   {codec: yamlCodec{}, tag: "yaml", extension: ".yaml"},
+		TOML:   {codec: tomlCodec{}, tag: "toml", extension: ".toml"},
+		INI:    {codec: iniCodec{}, tag: "ini", extension: ".ini"},
+		DotEnv: {codec: dotEnvCodec{}, tag: "dotenv", extension: ".env"},
+		JSON5:  {codec: json5Codec{}, tag: "json", extension: ".json5"},
+	}
+)
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// RegisterCodec makes format available to managers. Keys of config fields are
+// taken from struct tag named tag and files with given extensions (".ext") are
+// recognized as format. Registering existing format replaces its codec.
+func RegisterCodec(format SerializationFormat, tag string, codec Codec, extensions ...string) error {
+	if format == "" || tag == "" || codec == nil {
+		return fmt.Errorf("format, tag and codec must be set")
+	}
+	codecMu.Lock()
+	defer codecMu.Unlock()
+	entry := codecEntry{codec: codec, tag: tag, extension: "." + string(format)}
+	if old, ok := codecs[format]; ok {
+		entry.extension = old.extension
+	}
+	for i, ext := range extensions {
+		if !strings.HasPrefix(ext, ".") {
+			return fmt.Errorf("extension '%v' must start with dot", ext)
+		}
+		ext = strings.ToLower(ext)
+		if i == 0 {
+			entry.extension = ext
+		}
+		if _, ok := formatExtensions[ext]; !ok {
+			searchOrder = append(searchOrder, "config"+ext)
+		}
+		formatExtensions[ext] = format
+	}
+	codecs[format] = entry
+	return nil
+}
+
+// lookupCodec returns registered codec of format
+func lookupCodec(format SerializationFormat) (codecEntry, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	entry, ok := codecs[format]
+	return entry, ok
+}
+
+// tagName returns struct tag holding keys of format
+func tagName(format SerializationFormat) string {
+	if entry, ok := lookupCodec(format); ok {
+		return entry.tag
+	}
+	return string(format)
+}
+
+// formatExtension returns file extension used for config files of format
+func formatExtension(format SerializationFormat) string {
+	if entry, ok := lookupCodec(format); ok {
+		return entry.extension
+	}
+	return "." + string(format)
+}
+
+// jsonCodec handles JSON files, comments are allowed
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(stripJSONComments(data), v)
+}
+
+// yamlCodec handles YAML files
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v any) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+func (yamlCodec) Unmarshal(data []byte, v any) error {
+	return yaml.Unmarshal(data, v)
+}
+
+// tomlCodec handles TOML files
+type tomlCodec struct{}
+
+func (tomlCodec) Marshal(v any) ([]byte, error) {
+	return toml.Marshal(v)
+}
+
+func (tomlCodec) Unmarshal(data []byte, v any) error {
+	return toml.Unmarshal(data, v)
+}
+
+// quoted is a string value which was quoted in the file, so its type is never inferred
+type quoted string
+
+// parseLineDoc parses data of line based codecs (INI, dotenv) into document
+// of uninferred string values. ok is false for other codecs.
+func parseLineDoc(format SerializationFormat, data []byte) (doc map[string]any, ok bool, err error) {
+	entry, _ := lookupCodec(format)
+	switch entry.codec.(type) {
+	case iniCodec:
+		doc, err = parseINI(stripBOM(data))
+	case dotEnvCodec:
+		doc, err = parseDotEnv(stripBOM(data))
+	default:
+		return nil, false, nil
+	}
+	return doc, true, err
+}
+
+// unmarshalDoc decodes document parsed by line based codecs into target.
+// Document values are maps and strings converted to types of target fields.
+func unmarshalDoc(doc map[string]any, target any, format SerializationFormat) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("target must be non nil pointer")
+	}
+	return assignDoc(v.Elem(), doc, format)
+}
+
+// assignDoc sets document value to v. Interface values receive inferred types.
+func assignDoc(v reflect.Value, doc any, format SerializationFormat) error {
+	docMap, isMap := doc.(map[string]any)
+	switch {
+	case v.Kind() == reflect.Interface && v.NumMethod() == 0:
+		v.Set(reflect.ValueOf(inferDoc(doc)))
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return assignDoc(v.Elem(), doc, format)
+	case isMap && v.Kind() == reflect.Struct && !reflect.PointerTo(v.Type()).Implements(textUnmarshalerType):
+		for key, value := range docMap {
+			index, ok := findField(v.Type(), key, format)
+			if !ok {
+				continue
+			}
+			if err := assignDoc(fieldByIndex(v, index, true), value, format); err != nil {
+				return fmt.Errorf("%v: %v", key, err)
+			}
+		}
+	case isMap && v.Kind() == reflect.Map:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for key, value := range docMap {
+			mapKey := reflect.New(v.Type().Key()).Elem()
+			if err := setFromString(mapKey, key); err != nil {
+				return fmt.Errorf("%v: %v", key, err)
+			}
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if current := v.MapIndex(mapKey); current.IsValid() {
+				elem.Set(cloneValue(current))
+			}
+			if err := assignDoc(elem, value, format); err != nil {
+				return fmt.Errorf("%v: %v", key, err)
+			}
+			v.SetMapIndex(mapKey, elem)
+		}
+	case isMap:
+		return fmt.Errorf("can not decode section into %v", v.Type())
+	default:
+		return setFromString(v, fmt.Sprint(doc))
+	}
+	return nil
+}
+
+// inferDoc converts document strings into bools and numbers where possible
+func inferDoc(doc any) any {
+	switch value := doc.(type) {
+	case map[string]any:
+		inferred := make(map[string]any, len(value))
+		for key, item := range value {
+			inferred[key] = inferDoc(item)
+		}
+		return inferred
+	case quoted:
+		return string(value)
+	case string:
+		if b, err := strconv.ParseBool(value); err == nil && strings.ToLower(value) == strconv.FormatBool(b) {
+			return b
+		}
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i
+		}
+		if f, err := strconv.ParseFloat(value, 64); err == nil && strings.ContainsAny(value, ".eE") {
+			return f
+		}
+		return value
+	default:
+		return doc
+	}
+}
+
+// docEntry is a key of struct or map with its value
+type docEntry struct {
+	key   string
+	value reflect.Value
+}
+
+// docEntries returns keys of struct (in field order) or map (sorted) value.
+// Nil values are skipped.
+func docEntries(v reflect.Value, format SerializationFormat) []docEntry {
+	v = indirect(v)
+	entries := []docEntry{}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, field := range keyFields(v.Type(), format) {
+			if value := fieldByIndex(v, field.index, false); indirect(value).IsValid() {
+				entries = append(entries, docEntry{key: field.key, value: value})
+			}
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if indirect(v.MapIndex(key)).IsValid() {
+				entries = append(entries, docEntry{key: fmt.Sprint(key.Interface()), value: v.MapIndex(key)})
+			}
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].key < entries[j].key })
+	}
+	return entries
+}
+
+// isSection reports whether value is written as nested section by line based codecs
+func isSection(v reflect.Value) bool {
+	v = indirect(v)
+	if !v.IsValid() || v.Type() == durationType || v.Type().Implements(textMarshalerType) ||
+		reflect.PointerTo(v.Type()).Implements(textMarshalerType) {
+		return false
+	}
+	return v.Kind() == reflect.Struct || v.Kind() == reflect.Map
+}
+
+// scalarText returns text of scalar value. Slices are joined with commas, so
+// items with commas or surrounding spaces are rejected as they can not be read back.
+func scalarText(v reflect.Value) (string, error) {
+	v = indirect(v)
+	switch {
+	case !v.IsValid():
+		return "", nil
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String(), nil
+	case v.Type().Implements(textMarshalerType):
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	case reflect.PointerTo(v.Type()).Implements(textMarshalerType):
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		text, err := ptr.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(text), err
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			item, err := scalarText(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			if strings.Contains(item, ",") || strings.TrimSpace(item) != item || item == "" && v.Len() == 1 {
+				return "", fmt.Errorf("list item %q can not be written as comma separated value", item)
+			}
+			items = append(items, item)
+		}
+		return strings.Join(items, ","), nil
+	case reflect.Struct, reflect.Map:
+		return "", fmt.Errorf("%v value can not be written as scalar", v.Kind())
+	default:
+		return fmt.Sprint(v.Interface()), nil
+	}
+}
+
+// indirect dereferences pointers and interfaces. Invalid value is returned for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
@@ -0,0 +1,159 @@
+package configmanager
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, format := range []SerializationFormat{JSON, YAML, TOML, INI, DotEnv, JSON5} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config"+formatExtension(format))
+			m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithSerializationFormat(format))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			want := testConfig{
+				Name:   "say \"hi\" # not a comment",
+				Debug:  true,
+				Tags:   []string{"a", "b"},
+				Server: testServer{Host: "example.com", Port: 443, Timeout: 3 * time.Second},
+			}
+			for key, value := range map[string]any{"name": want.Name, "debug": true, "tags": want.Tags, "server": want.Server} {
+				if err := m.Set(key, value); err != nil {
+					t.Fatalf("Set(%v) failed: %v", key, err)
+				}
+			}
+			if err := m.Save(); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			loaded, err := New("testapp", testConfig{}, ForcePath(path), WithAutoFormat())
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := loaded.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if got := loaded.Config(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Config() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLineCodecs(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   SerializationFormat
+		data     string
+		debugKey string
+	}{
+		{
+			name:     "ini",
+			format:   INI,
+			debugKey: "debug",
+			data: "; legacy tool config\nname = app ; trailing comment\ndebug = true\ntags = a, b\n\n" +
+				"[server]\nhost = 'example.com'\nport = 443\ntimeout = 3s\n",
+		},
+		{
+			name:     "dotenv",
+			format:   DotEnv,
+			debugKey: "DEBUG",
+			data: "# legacy tool config\nexport NAME=app # trailing comment\nDEBUG=true\nTAGS=\"a,b\"\n" +
+				"SERVER__HOST=example.com\nserver__port=443\nSERVER__TIMEOUT=3s\n",
+		},
+		{
+			name:     "json5",
+			format:   JSON5,
+			debugKey: "debug",
+			data: "// legacy tool config\n{\n  name: 'app',\n  debug: true,\n  tags: ['a', \"b\",],\n" +
+				"  server: {host: \"example.com\", port: 0x1BB, timeout: 3000000000, /* ns */},\n}\n",
+		},
+	}
+	want := testConfig{Name: "app", Debug: true, Tags: []string{"a", "b"},
+		Server: testServer{Host: "example.com", Port: 443, Timeout: 3 * time.Second}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got testConfig
+			if err := decode(tt.format, []byte(tt.data), &got); err != nil {
+				t.Fatalf("decode() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decode() = %+v, want %+v", got, want)
+			}
+			doc := make(map[string]any)
+			if err := decode(tt.format, []byte(tt.data), &doc); err != nil {
+				t.Fatalf("decode() into document failed: %v", err)
+			}
+			if debug := doc[tt.debugKey]; debug != true {
+				t.Errorf("document %v = %#v, want true", tt.debugKey, debug)
+			}
+		})
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Marshal(v any) ([]byte, error) {
+	data, err := encode(JSON, v)
+	return bytes.ToUpper(data), err
+}
+
+func (upperCodec) Unmarshal(data []byte, v any) error {
+	return decode(JSON, bytes.ToLower(data), v)
+}
+
+func TestRegisterCodec(t *testing.T) {
+	if err := RegisterCodec("upper", "json", upperCodec{}, ".upper"); err != nil {
+		t.Fatalf("RegisterCodec() failed: %v", err)
+	}
+	if format, ok := FormatFromPath("config.UPPER"); !ok || format != "upper" {
+		t.Fatalf("FormatFromPath() = %v, %v, want upper", format, ok)
+	}
+	path := filepath.Join(t.TempDir(), "config.upper")
+	writeTestFile(t, path, `{"NAME": "APP"}`)
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithSerializationFormat("upper"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config().Name; got != "app" {
+		t.Errorf("Config().Name = %v, want app", got)
+	}
+	if err := RegisterCodec("", "json", upperCodec{}); err == nil {
+		t.Error("RegisterCodec() with empty format succeeded unexpectedly")
+	}
+}
+
+func TestLineCodecs_Lossless(t *testing.T) {
+	type lineConfig struct {
+		Tags   []string
+		Labels map[string]string
+	}
+	for _, format := range []SerializationFormat{INI, DotEnv} {
+		t.Run(string(format), func(t *testing.T) {
+			want := lineConfig{Tags: []string{"a", "b"}, Labels: map[string]string{"team": "core", "Env": "prod"}}
+			data, err := encode(format, want)
+			if err != nil {
+				t.Fatalf("encode() failed: %v", err)
+			}
+			var got lineConfig
+			if err := decode(format, data, &got); err != nil {
+				t.Fatalf("decode() failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decode() = %+v, want %+v\n%s", got, want, data)
+			}
+			for _, tags := range [][]string{{"a,b", "c"}, {" a"}, {""}} {
+				if data, err := encode(format, lineConfig{Tags: tags}); err == nil {
+					t.Errorf("encode() of tags %q succeeded:\n%s", tags, data)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package configmanager
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+var (
+	tomlTableLine = regexp.MustCompile(`^(\s*)\[\[?\s*([^\]]+?)\s*\]\]?\s*$`)
+	tomlKeyLine   = regexp.MustCompile(`^(\s*)("(?:[^"\\]|\\.)*"|'[^']*'|[A-Za-z0-9_\-]+)\s*=`)
+	yamlKeyLine   = regexp.MustCompile(`^(\s*)(- )?("(?:[^"\\]|\\.)*"|'[^']*'|[^\s:#"'][^:#]*?)\s*:(\s|$)`)
+)
+
+// fieldDoc holds documentation of a single config key taken from struct tags
+type fieldDoc struct {
+	comment string
+	example string
+	// native is set when comment comes from `comment` tag
+	// which TOML encoder writes by itself
+	native bool
+}
+
+// lines returns comment lines for the key
+func (d fieldDoc) lines() []string {
+	lines := []string{}
+	if d.comment != "" {
+		lines = append(lines, strings.Split(d.comment, "\n")...)
+	}
+	if d.example != "" {
+		lines = append(lines, "Example: "+d.example)
+	}
+	return lines
+}
+
+// collectDocs walks type t and gathers `comment` (or `doc`) and `example`
+// tags keyed by lowercased dotted key path
+func collectDocs(t reflect.Type, format SerializationFormat, prefix []string, docs map[string]fieldDoc) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		key, ok := fieldKey(field, format)
+		if !ok {
+			continue
+		}
+		keys := append(append([]string{}, prefix...), key)
+		doc := fieldDoc{comment: field.Tag.Get("comment"), example: field.Tag.Get("example")}
+		doc.native = doc.comment != ""
+		if doc.comment == "" {
+			doc.comment = field.Tag.Get("doc")
+		}
+		if doc.comment != "" || doc.example != "" {
+			docs[docKey(keys)] = doc
+		}
+		collectDocs(field.Type, format, keys, docs)
+	}
+}
+
+// annotate inserts comments from struct tags of T into serialized config.
+// Only TOML and YAML support comments, other formats are returned as is.
+func annotate[T any](format SerializationFormat, data []byte) []byte {
+	docs := make(map[string]fieldDoc)
+	collectDocs(reflect.TypeOf((*T)(nil)).Elem(), format, nil, docs)
+	if len(docs) == 0 {
+		return data
+	}
+	switch format {
+	case TOML:
+		return annotateTOML(data, docs)
+	case YAML:
+		return annotateYAML(data, docs)
+	default:
+		return data
+	}
+}
+
+// annotateTOML inserts comments above table headers and keys. Lines inside
+// multiline strings are skipped.
+func annotateTOML(data []byte, docs map[string]fieldDoc) []byte {
+	var out bytes.Buffer
+	table := []string{}
+	open := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if open != "" {
+			// line belongs to multiline string value
+		} else if match := tomlTableLine.FindStringSubmatch(line); match != nil {
+			table = []string{}
+			for _, key := range strings.Split(match[2], ".") {
+				table = append(table, unquoteKey(strings.TrimSpace(key)))
+			}
+			writeComments(&out, match[1], tomlDoc(docs[docKey(table)]))
+		} else if match := tomlKeyLine.FindStringSubmatch(line); match != nil {
+			keys := append(append([]string{}, table...), unquoteKey(match[2]))
+			writeComments(&out, match[1], tomlDoc(docs[docKey(keys)]))
+		}
+		open = tomlOpenString(line, open)
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	return out.Bytes()
+}
+
+// tomlOpenString returns delimiter of multiline string which is still open at
+// the end of line. Open is delimiter of string open at the start of line.
+func tomlOpenString(line, open string) string {
+	for i := 0; i < len(line); i++ {
+		switch {
+		case open == `"""` && line[i] == '\\':
+			i++
+		case open != "" && strings.HasPrefix(line[i:], open):
+			// closing delimiter may be preceded by up to two quotes of the value
+			for extra := 0; extra < 2 && i+3 < len(line) && line[i+3] == open[0]; extra++ {
+				i++
+			}
+			open, i = "", i+2
+		case open != "":
+		case strings.HasPrefix(line[i:], `"""`) || strings.HasPrefix(line[i:], "'''"):
+			open, i = line[i:i+3], i+2
+		case line[i] == '"':
+			for i++; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+		case line[i] == '\'':
+			if end := strings.IndexByte(line[i+1:], '\''); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(line)
+			}
+		case line[i] == '#':
+			return ""
+		}
+	}
+	return open
+}
+
+// tomlDoc drops comment already written by TOML encoder from `comment` tag
+func tomlDoc(doc fieldDoc) fieldDoc {
+	if doc.native {
+		doc.comment = ""
+	}
+	return doc
+}
+
+// yamlEntry is a key on the path to the current YAML line
+type yamlEntry struct {
+	key    string
+	indent int
+}
+
+// annotateYAML inserts comments above mapping keys. Keys inside sequences are skipped.
+func annotateYAML(data []byte, docs map[string]fieldDoc) []byte {
+	var out bytes.Buffer
+	stack := []yamlEntry{}
+	blockIndent := -1
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 {
+			if strings.TrimSpace(line) == "" || indent > blockIndent {
+				out.WriteString(line)
+				out.WriteString("\n")
+				continue
+			}
+			blockIndent = -1
+		}
+		match := yamlKeyLine.FindStringSubmatch(line)
+		if match == nil {
+			out.WriteString(line)
+			out.WriteString("\n")
+			continue
+		}
+		keyIndent := indent
+		if match[2] != "" {
+			for len(stack) > 0 && stack[len(stack)-1].indent > indent {
+				stack = stack[:len(stack)-1]
+			}
+			stack = append(stack, yamlEntry{key: "-", indent: indent + 1})
+			keyIndent = indent + 2
+		}
+		for len(stack) > 0 && stack[len(stack)-1].indent >= keyIndent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, yamlEntry{key: unquoteKey(match[3]), indent: keyIndent})
+		if match[2] == "" {
+			keys := make([]string, 0, len(stack))
+			for _, entry := range stack {
+				keys = append(keys, entry.key)
+			}
+			writeComments(&out, match[1], docs[docKey(keys)])
+		}
+		value := strings.TrimSpace(line[len(match[0]):])
+		if strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") {
+			blockIndent = keyIndent
+		}
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+	return out.Bytes()
+}
+
+// writeComments writes documentation lines with given indentation
+func writeComments(out *bytes.Buffer, indent string, doc fieldDoc) {
+	for _, line := range doc.lines() {
+		out.WriteString(indent)
+		out.WriteString("# ")
+		out.WriteString(line)
+		out.WriteString("\n")
+	}
+}
+
+// docKey returns lookup key for docs map
+func docKey(keys []string) string {
+	return strings.ToLower(strings.Join(keys, "."))
+}
+
+// unquoteKey removes quotes around serialized key
+func unquoteKey(key string) string {
+	if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+		return key[1 : len(key)-1]
+	}
+	return key
+}
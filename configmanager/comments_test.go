@@ -0,0 +1,118 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type documentedServer struct {
+	Host string `toml:"host" yaml:"host" comment:"Address to bind" example:"0.0.0.0"`
+	Port int    `toml:"port" yaml:"port" doc:"Port to listen on"`
+}
+
+type documentedConfig struct {
+	Name    string           `toml:"name" yaml:"name" comment:"Instance name"`
+	Motd    string           `toml:"motd" yaml:"motd"`
+	Servers []string         `toml:"servers" yaml:"servers" comment:"Upstream servers"`
+	Server  documentedServer `toml:"server" yaml:"server" comment:"Server section"`
+}
+
+func TestSave_Comments(t *testing.T) {
+	cfg := documentedConfig{
+		Name:    "main",
+		Motd:    "hello\nname: not a key",
+		Servers: []string{"a", "b"},
+		Server:  documentedServer{Host: "localhost", Port: 80},
+	}
+	tests := []struct {
+		format SerializationFormat
+		want   []string
+	}{
+		{
+			format: TOML,
+			want: []string{
+				"# Instance name\nname = 'main'",
+				"# Upstream servers\nservers = ",
+				"# Server section\n[server]",
+				"# Address to bind\n# Example: 0.0.0.0\nhost = 'localhost'",
+				"# Port to listen on\nport = 80",
+			},
+		},
+		{
+			format: YAML,
+			want: []string{
+				"# Instance name\nname: main",
+				"# Upstream servers\nservers:",
+				"# Server section\nserver:",
+				"  # Address to bind\n  # Example: 0.0.0.0\n  host: localhost",
+				"  # Port to listen on\n  port: 80",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config."+string(tt.format))
+			m, err := New("testapp", cfg, ForcePath(path), WithSerializationFormat(tt.format))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Save(); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read config: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("saved config does not contain %q:\n%s", want, data)
+				}
+			}
+			if strings.Count(string(data), "# Instance name") != 1 {
+				t.Errorf("comment duplicated:\n%s", data)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if got := m.Config(); got.Motd != cfg.Motd || got.Server != cfg.Server {
+				t.Errorf("Config() = %+v, want %+v", got, cfg)
+			}
+		})
+	}
+}
+
+func TestSave_CommentsMultiline(t *testing.T) {
+	type multilineConfig struct {
+		Motd string `toml:"motd,multiline"`
+		Port int    `toml:"port" doc:"listen port"`
+	}
+	cfg := multilineConfig{Motd: "hello\nport = 2\n'''\nbye \"\"\"", Port: 1}
+	path := filepath.Join(t.TempDir(), "config.toml")
+	m, err := New("testapp", cfg, ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config(); got != cfg {
+		data, _ := os.ReadFile(path)
+		t.Errorf("Config() = %+v, want %+v\n%s", got, cfg, data)
+	}
+
+	docs := map[string]fieldDoc{"port": {comment: "listen port"}}
+	for _, data := range []string{
+		"motd = '''\nport = 2\n'''\nport = 1\n",
+		"motd = \"\"\"\nport = 2 \\\"\"\"\nx\"\"\"\"\"\nport = 1\n",
+	} {
+		got := string(annotateTOML([]byte(data), docs))
+		if strings.Count(got, "# listen port") != 1 || !strings.HasSuffix(got, "# listen port\nport = 1\n") {
+			t.Errorf("annotateTOML() = %q", got)
+		}
+	}
+}
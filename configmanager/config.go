@@ -1,17 +1,16 @@
 package configmanager
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/Galdoba/appcontext/xdg"
-	"github.com/goccy/go-yaml"
-	"github.com/pelletier/go-toml/v2"
 )
 
 // Library version constant
@@ -19,13 +18,20 @@ const (
 	LibVersion = "0.2.1"
 )
 
-// SerializationFormat represents supported configuration file formats
+// defaultFileName is the name of config file without extension
+const defaultFileName = "config"
+
+// SerializationFormat represents supported configuration file formats.
+// Additional formats are added with RegisterCodec.
 type SerializationFormat string
 
 const (
-	JSON SerializationFormat = "json"
-	YAML SerializationFormat = "yaml"
-	TOML SerializationFormat = "toml"
+	JSON   SerializationFormat = "json"
+	YAML   SerializationFormat = "yaml"
+	TOML   SerializationFormat = "toml"
+	INI    SerializationFormat = "ini"
+	DotEnv SerializationFormat = "dotenv"
+	JSON5  SerializationFormat = "json5"
 )
 
 // Validator interface for configuration validation
@@ -35,16 +41,93 @@ type Validator interface {
 
 // Manager is the main configuration manager that handles loading, saving, and managing configuration files
 type Manager[T any] struct {
-	mu     sync.RWMutex
-	config *T
-	path   string
-	format SerializationFormat
+	mu          sync.RWMutex
+	appName     string
+	fileName    string
+	fs          FS
+	config      *T
+	defaults    *T
+	path        string
+	format      SerializationFormat
+	autoFormat  bool
+	systemPath  string
+	systemDirs  bool
+	projectPath string
+	envPrefix   string
+	overrides   map[string]string
+
+	// base is the user file layer: defaults overlaid with the user file and
+	// values changed by Set and Reset. Save writes it, never the resolved
+	// config. owned holds keys of the user file and keys changed since.
+	base       *T
+	owned      keyTree
+	systemKeys keyTree
+
+	schemaVersion int
+	migrations    map[int]MigrationFunc
+	mergeDefaults bool
+
+	preserveFormatting bool
+
+	keyPath string
+	secrets bool
+
+	profile    string
+	sliceMerge SliceMergeMode
+
+	backups   int
+	backupDir string
+
+	audit     bool
+	auditPath string
+
+	checksum string
+
+	schemaValidation bool
+	unknownKeys      UnknownKeyMode
+	warnings         []FieldError
+	fragments        []string
+
+	interpolation bool
+	templates     map[string]template
+
+	sources []Source
+
+	watchInterval  time.Duration
+	subMu          sync.Mutex
+	subscribers    map[int]ChangeHandler[T]
+	nextSubscriber int
 }
 
 // managerOptions holds configuration options for the Manager
 type managerOptions struct {
 	forceAlternativePath string
+	fileName             string
+	fs                   FS
 	format               SerializationFormat
+	autoFormat           bool
+	systemPath           string
+	projectPath          string
+	envPrefix            string
+	overrides            map[string]string
+	watchInterval        time.Duration
+	schemaVersion        int
+	migrations           map[int]MigrationFunc
+	mergeDefaults        bool
+	preserveFormatting   bool
+	keyPath              string
+	profile              string
+	profileEnv           string
+	profileFromEnv       bool
+	sliceMerge           SliceMergeMode
+	backups              int
+	backupDir            string
+	schemaValidation     bool
+	unknownKeys          UnknownKeyMode
+	interpolation        bool
+	systemDirs           bool
+	audit                bool
+	auditPath            string
 }
 
 // ManagerOption defines function type for configuring Manager options
@@ -58,11 +141,20 @@ type ErrUnsupportedFormat struct {
 // New creates a new configuration Manager with the specified application name and default configuration
 func New[T any](appName string, defaultConfig T, options ...ManagerOption) (*Manager[T], error) {
 	m := &Manager[T]{
-		config: &defaultConfig,
+		appName:    appName,
+		config:     &defaultConfig,
+		defaults:   cloneConfig(&defaultConfig),
+		base:       cloneConfig(&defaultConfig),
+		owned:      keyTree{},
+		systemKeys: keyTree{},
 	}
 	mo := managerOptions{
 		forceAlternativePath: "",
+		fileName:             defaultFileName,
 		format:               TOML,
+		overrides:            make(map[string]string),
+		watchInterval:        defaultWatchInterval,
+		migrations:           make(map[int]MigrationFunc),
 	}
 	for _, modify := range options {
 		modify(&mo)
@@ -71,23 +163,102 @@ func New[T any](appName string, defaultConfig T, options ...ManagerOption) (*Man
 	if err := validateFormat(mo.format); err != nil {
 		return nil, err
 	}
+	if mo.fileName == "" || strings.ContainsAny(mo.fileName, `/\`) {
+		return nil, fmt.Errorf("invalid file name '%v'", mo.fileName)
+	}
+	m.fileName = mo.fileName
+	m.fs = mo.fs
+	if m.fs == nil {
+		m.fs = osFS{}
+	}
 	m.format = mo.format
+	m.autoFormat = mo.autoFormat
 
 	switch mo.forceAlternativePath {
 	case "":
-		m.path = xdg.Location(xdg.ForConfig(), xdg.WithProgramName(appName), xdg.WithFileName(fmt.Sprintf("config.%v", m.format)))
+		m.path = xdg.Location(xdg.ForConfig(), xdg.WithProgramName(appName), xdg.WithFileName(m.fileName+formatExtension(m.format)))
+		if m.autoFormat {
+			if path, format, ok := searchConfigFile(m.fs, filepath.Dir(m.path), m.fileName); ok {
+				m.path, m.format = path, format
+			}
+		}
 	default:
-		if err := validatePathFormatConsistency(mo.forceAlternativePath, m.format); err != nil {
+		if m.autoFormat {
+			format, err := detectFormat(m.fs, mo.forceAlternativePath)
+			if err != nil {
+				return nil, err
+			}
+			m.format = format
+		}
+		if err := validatePathFormatConsistency(mo.forceAlternativePath, m.format); err != nil && !m.autoFormat {
 			return nil, err
 		}
-		if fileExists(mo.forceAlternativePath) {
-			if err := validatePath(mo.forceAlternativePath); err != nil {
+		if fileExists(m.fs, mo.forceAlternativePath) {
+			if err := validatePath(m.fs, mo.forceAlternativePath); err != nil {
 				return nil, fmt.Errorf("forced path invalid: %v", err)
 			}
 		}
 		m.path = mo.forceAlternativePath
 	}
 
+	for _, layerPath := range []string{mo.systemPath, mo.projectPath} {
+		if layerPath == "" || m.autoFormat {
+			continue
+		}
+		if err := validatePathFormatConsistency(layerPath, m.format); err != nil {
+			return nil, fmt.Errorf("layer %v: %v", layerPath, err)
+		}
+	}
+	m.systemPath = mo.systemPath
+	m.systemDirs = mo.systemDirs
+	m.projectPath = mo.projectPath
+	m.envPrefix = mo.envPrefix
+	m.overrides = mo.overrides
+	m.watchInterval = mo.watchInterval
+
+	if err := checkRules(reflect.TypeOf((*T)(nil)).Elem(), make(map[reflect.Type]bool)); err != nil {
+		return nil, err
+	}
+	if err := validateVersioning[T](mo.schemaVersion, m.format); err != nil {
+		return nil, err
+	}
+	m.schemaVersion = mo.schemaVersion
+	m.migrations = mo.migrations
+	m.mergeDefaults = mo.mergeDefaults
+	m.preserveFormatting = mo.preserveFormatting
+	m.schemaValidation = mo.schemaValidation
+	m.unknownKeys = mo.unknownKeys
+	m.interpolation = mo.interpolation
+
+	secrets, err := hasSecrets(reflect.TypeOf((*T)(nil)).Elem(), make(map[reflect.Type]bool))
+	if err != nil {
+		return nil, err
+	}
+	m.secrets = secrets
+	profile, err := selectProfile(appName, mo)
+	if err != nil {
+		return nil, err
+	}
+	m.profile = profile
+	m.sliceMerge = mo.sliceMerge
+
+	m.backups = mo.backups
+	m.backupDir = mo.backupDir
+	if m.backupDir == "" {
+		m.backupDir = defaultBackupDir(appName, m.fileName)
+	}
+
+	m.audit = mo.audit
+	m.auditPath = mo.auditPath
+	if m.auditPath == "" {
+		m.auditPath = xdg.Location(xdg.ForState(), xdg.WithProgramName(appName), xdg.WithFileName("audit.log"))
+	}
+
+	m.keyPath = mo.keyPath
+	if m.keyPath == "" {
+		m.keyPath = xdg.Location(xdg.ForData(), xdg.WithProgramName(appName), xdg.WithFileName("secret.key"))
+	}
+
 	return m, nil
 }
 
@@ -98,6 +269,15 @@ func ForcePath(path string) ManagerOption {
 	}
 }
 
+// WithFileName option sets name of config file without extension, "config" by
+// default. It lets several managers of one application keep separate files
+// (ui.toml, network.yaml) in the application config directory.
+func WithFileName(name string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.fileName = name
+	}
+}
+
 // WithSerializationFormat option sets the serialization format for the configuration file
 func WithSerializationFormat(format SerializationFormat) ManagerOption {
 	return func(mo *managerOptions) {
@@ -105,58 +285,225 @@ func WithSerializationFormat(format SerializationFormat) ManagerOption {
 	}
 }
 
-// Load reads and parses the configuration file from disk
+// Load resolves the configuration: defaults, system file, user file, project file,
+// environment variables and explicit overrides, each layer overriding the previous one
 func (m *Manager[T]) Load() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	unlock, err := m.lock(m.schemaVersion > 0)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return m.load()
+}
+
+// load resolves and validates the configuration. Caller must hold both
+// in-process and file locks. Current configuration is kept intact if any step fails.
+func (m *Manager[T]) load() error {
+	res, err := m.prepare()
+	if err != nil {
+		return err
+	}
+	return m.apply(res)
+}
+
+// prepare resolves and validates the configuration without applying it
+func (m *Manager[T]) prepare() (*resolution[T], error) {
 	if m.path == "" {
-		return fmt.Errorf("filepath is not set")
+		return nil, fmt.Errorf("filepath is not set")
 	}
-	if ext := strings.TrimPrefix(filepath.Ext(m.path), "."); ext != string(m.format) {
-		return fmt.Errorf("file is extention does not match serialization format (%v; %v)", ext, m.format)
+	if !m.autoFormat {
+		if err := validatePathFormatConsistency(m.path, m.format); err != nil {
+			return nil, err
+		}
 	}
-	data, err := os.ReadFile(m.path)
+	res, err := m.resolve()
 	if err != nil {
-		return fmt.Errorf("failed to read selected file: %v", err)
+		return nil, err
 	}
+	if err := m.validate(res.config); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+	return res, nil
+}
 
-	if err := m.unmarshal(data); err != nil {
+// apply makes prepared resolution the current configuration, saving migrated
+// config file if needed
+func (m *Manager[T]) apply(res *resolution[T]) error {
+	commit, err := m.saveMigrated(res)
+	if err != nil {
 		return err
 	}
-
-	if v, ok := any(m.config).(Validator); ok {
-		if err := v.Validate(); err != nil {
-			return fmt.Errorf("config validation failed: %w", err)
-		}
+	m.use(res)
+	if commit != nil {
+		return commit()
 	}
 	return nil
 }
 
-// Save writes the current configuration to disk
+// saveMigrated writes migrated config file of res the way Save would write it
+// and returns function keeping backup of replaced file. Migrated config of
+// read-only filesystem is kept in memory. Returned function is nil if config
+// file was not written.
+func (m *Manager[T]) saveMigrated(res *resolution[T]) (func() error, error) {
+	res.checksum = checksum(res.userFile)
+	if !res.migrated {
+		return nil, nil
+	}
+	data, err := m.render(res.base, res.owned, m.format, m.preserveFormatting)
+	if err == nil {
+		err = m.fs.WriteFile(m.path, data, m.filePerm())
+	}
+	switch {
+	case errors.Is(err, ErrReadOnly):
+		return nil, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to save migrated config: %v", err)
+	}
+	res.checksum = checksum(data)
+	return func() error {
+		if err := m.keepBackup(res.userFile, data); err != nil {
+			return fmt.Errorf("migrated config saved but backup failed: %v", err)
+		}
+		return nil
+	}, nil
+}
+
+// use makes resolution the current configuration
+func (m *Manager[T]) use(res *resolution[T]) {
+	m.checksum = res.checksum
+	m.config = res.config
+	m.base, m.owned, m.systemKeys = res.base, res.owned, res.systemKeys
+	m.warnings = res.warnings
+	m.fragments = res.fragments
+	m.templates = res.templates
+	m.sources = res.sources
+}
+
+// Save writes the user file: values it was loaded with and values changed by
+// Set and Reset. Values of other files, environment and overrides are never
+// written. ErrConflict is returned if the file was changed by another process
+// since last Load or Save.
 func (m *Manager[T]) Save() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if validator, ok := any(m.config).(Validator); ok {
-		if err := validator.Validate(); err != nil {
-			return fmt.Errorf("validation failed before save: %w", err)
-		}
+	unlock, err := m.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := m.validate(m.config); err != nil {
+		return fmt.Errorf("validation failed before save: %w", err)
+	}
+
+	m.stampVersion(m.base)
+	return m.write(m.base, m.owned)
+}
+
+// write saves user layer cfg with keys of owned to config path, creating
+// directory if needed. Caller must hold both in-process and exclusive file locks.
+func (m *Manager[T]) write(cfg *T, owned keyTree) error {
+	data, err := m.prepareWrite(cfg, owned)
+	if err != nil {
+		return err
 	}
+	return m.writeData(data)
+}
 
-	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
-		return fmt.Errorf("failed to enshure config directory: %v", err)
+// prepareWrite ensures config directory, checks that the file was not changed
+// by another process and returns content of user layer cfg to be written
+func (m *Manager[T]) prepareWrite(cfg *T, owned keyTree) ([]byte, error) {
+	if err := m.fs.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to enshure config directory: %v", err)
 	}
+	if err := m.checkConflict(); err != nil {
+		return nil, err
+	}
+	return m.render(cfg, owned, m.format, m.preserveFormatting)
+}
 
-	data, err := m.marshal()
+// render serializes user layer cfg in format as content of config file. Every
+// key is written unless system files are used, then only keys of owned are written.
+func (m *Manager[T]) render(cfg *T, owned keyTree, format SerializationFormat, preserve bool) ([]byte, error) {
+	sealed := cloneConfig(cfg)
+	m.restoreTemplates(sealed)
+	if err := m.sealSecrets(sealed); err != nil {
+		return nil, fmt.Errorf("failed to encrypt secrets: %w", err)
+	}
+	var out any = sealed
+	if !m.ownsAllKeys() {
+		keys := owned.clone()
+		if m.schemaVersion > 0 {
+			keys.add([]string{VersionKey})
+		}
+		out = ownedValue(reflect.ValueOf(sealed).Elem(), keys, m.format).Interface()
+	}
+	data, err := encode(format, out)
 	if err != nil {
-		return fmt.Errorf("failed to marshal data: %v", err)
+		return nil, fmt.Errorf("failed to marshal data: %v", err)
+	}
+	data = annotate[T](format, data)
+	if data, err = m.keepIncludes(format, data); err != nil {
+		return nil, fmt.Errorf("failed to keep include directive: %v", err)
+	}
+	if preserve && format == m.format {
+		if patched, ok := m.preserve(data); ok {
+			data = patched
+		}
 	}
-	if err := atomicSave(data, m.path); err != nil {
+	return data, nil
+}
+
+// writeData replaces config file with data, keeping backup and audit log
+func (m *Manager[T]) writeData(data []byte) error {
+	changes, err := m.auditChanges(data)
+	if err != nil {
+		return err
+	}
+	if err := m.backup(data); err != nil {
+		return err
+	}
+	if err := m.fs.WriteFile(m.path, data, m.filePerm()); err != nil {
 		return fmt.Errorf("atomic save: %v", err)
 	}
+	m.checksum = checksum(data)
 
+	if err := m.appendAudit(changes); err != nil {
+		return fmt.Errorf("config saved but audit failed: %v", err)
+	}
 	return nil
 }
 
+// stageData replaces config file with data like writeData, but backup of
+// replaced content and audit entry are only kept when returned function is called
+func (m *Manager[T]) stageData(data []byte) (func() error, error) {
+	changes, err := m.auditChanges(data)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := m.fs.ReadFile(m.path)
+	existed := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read config for backup: %v", err)
+	}
+	if err := m.fs.WriteFile(m.path, data, m.filePerm()); err != nil {
+		return nil, fmt.Errorf("atomic save: %v", err)
+	}
+	m.checksum = checksum(data)
+	return func() error {
+		if existed {
+			if err := m.keepBackup(previous, data); err != nil {
+				return fmt.Errorf("config saved but backup failed: %v", err)
+			}
+		}
+		if err := m.appendAudit(changes); err != nil {
+			return fmt.Errorf("config saved but audit failed: %v", err)
+		}
+		return nil
+	}, nil
+}
+
 // Config returns the current configuration
 func (m *Manager[T]) Config() T {
 	m.mu.RLock()
@@ -171,58 +518,63 @@ func (m *Manager[T]) Path() string {
 	return m.path
 }
 
+// FileExists reports whether config file exists on filesystem of the manager
+func (m *Manager[T]) FileExists() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return fileExists(m.fs, m.path)
+}
+
 // Error implements the error interface for ErrUnsupportedFormat
 func (err *ErrUnsupportedFormat) Error() string {
 	return fmt.Sprintf("unsupported serialization format: '%v'", err.format)
 }
 
-// unmarshal deserializes data based on the configured format
-func (m *Manager[T]) unmarshal(data []byte) error {
-	switch m.format {
-	case JSON:
-		return json.Unmarshal(data, m.config)
-	case YAML:
-		return yaml.Unmarshal(data, m.config)
-	case TOML:
-		return toml.Unmarshal(data, m.config)
-	default:
-		return &ErrUnsupportedFormat{m.format}
+// marshal serializes cfg based on the configured format.
+// TOML and YAML output is annotated with `comment` and `example` tags of T.
+func (m *Manager[T]) marshal(cfg *T) ([]byte, error) {
+	data, err := encode(m.format, cfg)
+	if err != nil {
+		return nil, err
 	}
+	return annotate[T](m.format, data), nil
 }
 
-// marshal serializes the configuration based on the configured format
-func (m *Manager[T]) marshal() ([]byte, error) {
-	switch m.format {
-	case JSON:
-		return json.Marshal(m.config)
-	case YAML:
-		return yaml.Marshal(m.config)
-	case TOML:
-		return toml.Marshal(m.config)
-	default:
-		return nil, &ErrUnsupportedFormat{m.format}
+// decode deserializes data in the given format into target
+func decode(format SerializationFormat, data []byte, target any) error {
+	entry, ok := lookupCodec(format)
+	if !ok {
+		return &ErrUnsupportedFormat{format}
+	}
+	return entry.codec.Unmarshal(stripBOM(data), target)
+}
+
+// encode serializes v in the given format
+func encode(format SerializationFormat, v any) ([]byte, error) {
+	entry, ok := lookupCodec(format)
+	if !ok {
+		return nil, &ErrUnsupportedFormat{format}
 	}
+	return entry.codec.Marshal(v)
 }
 
 // validateFormat checks if the provided format is supported
 func validateFormat(format SerializationFormat) error {
-	switch format {
-	case JSON, YAML, TOML:
-		return nil
-	default:
+	if _, ok := lookupCodec(format); !ok {
 		return &ErrUnsupportedFormat{format}
 	}
+	return nil
 }
 
-// validatePath checks if a file path is valid and accessible
-func validatePath(filePath string) error {
+// validatePath checks if a file path of fsys is valid and accessible
+func validatePath(fsys FS, filePath string) error {
 	if filePath == "" {
 		return fmt.Errorf("path is empty")
 	}
-	if !fileExists(filePath) {
+	if !fileExists(fsys, filePath) {
 		return nil
 	}
-	info, err := os.Stat(filePath)
+	info, err := fsys.Stat(filePath)
 	if err != nil {
 		return err
 	}
@@ -231,28 +583,32 @@ func validatePath(filePath string) error {
 		return errors.New("path is directory")
 	}
 
-	file, err := os.OpenFile(filePath, os.O_RDONLY, 0666)
-	if err != nil {
+	if _, err := fsys.ReadFile(filePath); err != nil {
 		return fmt.Errorf("failed to open file: %v", err)
 	}
-	defer file.Close()
 
 	return nil
 }
 
-// fileExists checks if a file exists at the given path
-func fileExists(path string) bool {
-	info, err := os.Stat(path)
+// atomicSave saves data to a temporary file then renames it to the target path
+func atomicSave(data []byte, path string, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp.*")
 	if err != nil {
-		return false
+		return fmt.Errorf("failed to create tmp file: %v", err)
 	}
-	return info.Mode().IsRegular()
-}
-
-// atomicSave saves data to a temporary file then renames it to the target path
-func atomicSave(data []byte, path string) error {
-	tempPath := path + ".tmp"
-	if err := os.WriteFile(tempPath, data, 0644); err != nil {
+	tempPath := tmpFile.Name()
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tempPath, perm)
+	}
+	if err != nil {
+		os.Remove(tempPath)
 		return fmt.Errorf("failed to save to tmp file: %v", err)
 	}
 	if err := os.Rename(tempPath, path); err != nil {
@@ -262,22 +618,12 @@ func atomicSave(data []byte, path string) error {
 	return nil
 }
 
+// validatePathFormatConsistency checks that path extension matches serialization format
 func validatePathFormatConsistency(path string, format SerializationFormat) error {
-	switch format {
-	case JSON:
-		if strings.HasSuffix(path, "json") {
-			return nil
-		}
-	case YAML:
-		if strings.HasSuffix(path, "yaml") {
-			return nil
-		}
-	case TOML:
-		if strings.HasSuffix(path, "toml") {
-			return nil
-		}
+	if detected, ok := FormatFromPath(path); ok && detected == format {
+		return nil
 	}
-	return fmt.Errorf("path does not match with format")
+	return fmt.Errorf("path %v does not match with format %v (use WithAutoFormat to detect format from file)", path, format)
 }
 
 // SetPath sets new path for config file
@@ -285,6 +631,15 @@ func (m *Manager[T]) SetPath(newPath string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.autoFormat {
+		format, err := detectFormat(m.fs, newPath)
+		if err != nil {
+			return err
+		}
+		m.format = format
+		m.path = newPath
+		return nil
+	}
 	if err := validatePathFormatConsistency(newPath, m.format); err != nil {
 		return err
 	}
@@ -0,0 +1,338 @@
+// Package configcmd implements `config` subcommands for applications using
+// configmanager: show, get, set, edit, path, reset and validate.
+//
+// Application passes arguments following its `config` command to Run:
+//
+//	cmd := configcmd.New(manager, configcmd.WithName("myapp config"))
+//	if err := cmd.Run(os.Args[2:]); err != nil {
+//		fmt.Fprintln(os.Stderr, err)
+//		os.Exit(1)
+//	}
+package configcmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/Galdoba/appcontext/configmanager"
+)
+
+// Manager is configuration manager driven by commands.
+// *configmanager.Manager[T] implements it.
+type Manager interface {
+	Load() error
+	LoadOrCreate() error
+	GetRedacted(keyPath string) (any, error)
+	SetAndSave(keyPath string, value any) error
+	Reset(keyPaths ...string) error
+	ValidateFile(path string) error
+	Warnings() []configmanager.FieldError
+	Sources() []configmanager.Source
+	Path() string
+	FileExists() bool
+	String() string
+}
+
+// Command runs config subcommands against Manager
+type Command struct {
+	manager Manager
+	name    string
+	editor  string
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+// CommandOption defines function type for configuring Command
+type CommandOption func(*Command)
+
+// New creates Command managing configuration of m
+func New(m Manager, options ...CommandOption) *Command {
+	c := &Command{
+		manager: m,
+		name:    "config",
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}
+	for _, modify := range options {
+		modify(c)
+	}
+	return c
+}
+
+// WithName option sets command name shown in usage (e.g. "myapp config")
+func WithName(name string) CommandOption {
+	return func(c *Command) {
+		c.name = name
+	}
+}
+
+// WithEditor option sets editor command used by edit. By default $VISUAL or
+// $EDITOR is used, falling back to vi (notepad on Windows).
+func WithEditor(editor string) CommandOption {
+	return func(c *Command) {
+		c.editor = editor
+	}
+}
+
+// WithIO option sets streams used instead of standard input and outputs
+func WithIO(stdin io.Reader, stdout, stderr io.Writer) CommandOption {
+	return func(c *Command) {
+		c.stdin = stdin
+		c.stdout = stdout
+		c.stderr = stderr
+	}
+}
+
+// Usage returns help text of subcommands
+func (c *Command) Usage() string {
+	return fmt.Sprintf(`Usage: %[1]v <command> [arguments]
+
+Commands:
+  show                 print configuration, secrets are redacted
+  get <key>            print value of dotted key path, secrets are redacted
+  set <key> <value>    set value of dotted key path and save
+  edit                 open config file in editor and validate it
+  path [--all]         print config file path, --all lists every loaded file
+  reset [-y] [key...]  restore default values of keys or whole configuration
+  validate             check config file
+`, c.name)
+}
+
+// Run executes subcommand given by args
+func (c *Command) Run(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprint(c.stdout, c.Usage())
+		return nil
+	}
+	name, args := args[0], args[1:]
+	switch name {
+	case "show":
+		return c.show(args)
+	case "get":
+		return c.get(args)
+	case "set":
+		return c.set(args)
+	case "edit":
+		return c.edit(args)
+	case "path":
+		return c.path(args)
+	case "reset":
+		return c.reset(args)
+	case "validate":
+		return c.validate(args)
+	case "help", "-h", "--help":
+		fmt.Fprint(c.stdout, c.Usage())
+		return nil
+	default:
+		return fmt.Errorf("unknown command '%v'\n\n%v", name, c.Usage())
+	}
+}
+
+// show prints configuration with secrets redacted
+func (c *Command) show(args []string) error {
+	if err := c.expectArgs("show", args, 0); err != nil {
+		return err
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+	out := c.manager.String()
+	if !strings.HasSuffix(out, "\n") {
+		out += "\n"
+	}
+	fmt.Fprint(c.stdout, out)
+	return nil
+}
+
+// get prints value of key path with secrets redacted, sections are printed as JSON
+func (c *Command) get(args []string) error {
+	if err := c.expectArgs("get <key>", args, 1); err != nil {
+		return err
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+	value, err := c.manager.GetRedacted(args[0])
+	if err != nil {
+		return err
+	}
+	switch reflect.Indirect(reflect.ValueOf(value)).Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		if _, ok := value.(fmt.Stringer); !ok {
+			data, err := json.MarshalIndent(value, "", "  ")
+			if err != nil {
+				return fmt.Errorf("failed to print %v: %v", args[0], err)
+			}
+			fmt.Fprintln(c.stdout, string(data))
+			return nil
+		}
+	}
+	fmt.Fprintln(c.stdout, value)
+	return nil
+}
+
+// set assigns value of key path and saves configuration
+func (c *Command) set(args []string) error {
+	if err := c.expectArgs("set <key> <value>", args, 2); err != nil {
+		return err
+	}
+	if err := c.load(); err != nil {
+		return err
+	}
+	return c.manager.SetAndSave(args[0], args[1])
+}
+
+// edit opens config file in editor until it passes validation or user gives up
+func (c *Command) edit(args []string) error {
+	if err := c.expectArgs("edit", args, 0); err != nil {
+		return err
+	}
+	if !c.manager.FileExists() {
+		if err := c.manager.LoadOrCreate(); err != nil {
+			return err
+		}
+	}
+	input := bufio.NewReader(c.stdin)
+	for {
+		if err := c.runEditor(); err != nil {
+			return err
+		}
+		err := c.check()
+		if err == nil {
+			return nil
+		}
+		fmt.Fprintf(c.stderr, "%v\nEdit again? [Y/n] ", err)
+		answer, readErr := input.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "n" || answer == "no" || readErr != nil && answer == "" {
+			return err
+		}
+	}
+}
+
+// runEditor opens config file in editor and waits for it to exit
+func (c *Command) runEditor() error {
+	editor := c.editor
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor == "" {
+			editor = os.Getenv(name)
+		}
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], c.manager.Path())...)
+	// editor needs terminal, so it never reads from streams set by WithIO
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %v failed: %v", fields[0], err)
+	}
+	return nil
+}
+
+// path prints config file path or every file of the loaded configuration
+func (c *Command) path(args []string) error {
+	if len(args) == 1 && args[0] == "--all" {
+		if err := c.manager.Load(); err != nil {
+			return err
+		}
+		for _, source := range c.manager.Sources() {
+			fmt.Fprintf(c.stdout, "%v\t%v\n", source.Kind, source.Path)
+		}
+		return nil
+	}
+	if err := c.expectArgs("path [--all]", args, 0); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, c.manager.Path())
+	return nil
+}
+
+// reset restores default values after confirmation unless -y is given
+func (c *Command) reset(args []string) error {
+	keys := []string{}
+	confirmed := false
+	for _, arg := range args {
+		switch {
+		case arg == "-y" || arg == "--yes":
+			confirmed = true
+		case strings.HasPrefix(arg, "-"):
+			return fmt.Errorf("unknown flag '%v'\nusage: %v reset [-y] [key...]", arg, c.name)
+		default:
+			keys = append(keys, arg)
+		}
+	}
+	// invalid or unreadable config file is what reset recovers from, so only
+	// loadable user values are kept
+	c.manager.Load()
+	if !confirmed {
+		what := "configuration"
+		if len(keys) > 0 {
+			what = strings.Join(keys, ", ")
+		}
+		fmt.Fprintf(c.stderr, "Reset %v to defaults? [y/N] ", what)
+		answer, _ := bufio.NewReader(c.stdin).ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer != "y" && answer != "yes" {
+			return fmt.Errorf("reset aborted")
+		}
+	}
+	return c.manager.Reset(keys...)
+}
+
+// validate checks config file and prints warnings
+func (c *Command) validate(args []string) error {
+	if err := c.expectArgs("validate", args, 0); err != nil {
+		return err
+	}
+	if err := c.check(); err != nil {
+		return err
+	}
+	for _, warning := range c.manager.Warnings() {
+		fmt.Fprintf(c.stderr, "warning: %v\n", warning)
+	}
+	fmt.Fprintf(c.stdout, "%v: ok\n", c.manager.Path())
+	return nil
+}
+
+// check validates config file against schema and loads it
+func (c *Command) check() error {
+	if err := c.manager.ValidateFile(c.manager.Path()); err != nil {
+		return fmt.Errorf("config is invalid: %w", err)
+	}
+	if err := c.manager.Load(); err != nil {
+		return fmt.Errorf("config is invalid: %w", err)
+	}
+	return nil
+}
+
+// load loads configuration. Defaults are used if config file does not exist.
+func (c *Command) load() error {
+	if err := c.manager.Load(); err != nil && c.manager.FileExists() {
+		return err
+	}
+	return nil
+}
+
+// expectArgs checks number of subcommand arguments
+func (c *Command) expectArgs(usage string, args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("expected %v argument(s), got %v\nusage: %v %v", n, len(args), c.name, usage)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package configcmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Galdoba/appcontext/configmanager"
+)
+
+type testConfig struct {
+	Name   string `toml:"name"`
+	Token  string `toml:"token" secret:"true"`
+	Server struct {
+		Port int    `toml:"port" validate:"min=1"`
+		Key  string `toml:"key" secret:"true"`
+	} `toml:"server"`
+}
+
+func newTestCommand(t *testing.T, stdin string, options ...CommandOption) (*Command, *configmanager.Manager[testConfig], *bytes.Buffer) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := testConfig{Name: "default", Token: "s3cr3t"}
+	cfg.Server.Port = 8080
+	cfg.Server.Key = "k3y"
+	m, err := configmanager.New("testapp", cfg,
+		configmanager.ForcePath(filepath.Join(dir, "config.toml")),
+		configmanager.WithKeyFile(filepath.Join(dir, "secret.key")),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	options = append([]CommandOption{WithIO(strings.NewReader(stdin), stdout, &bytes.Buffer{})}, options...)
+	return New(m, options...), m, stdout
+}
+
+func TestCommand(t *testing.T) {
+	cmd, m, stdout := newTestCommand(t, "")
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"get", "server.port"}, want: "8080\n"},
+		{args: []string{"set", "server.port", "9090"}},
+		{args: []string{"get", "server.port"}, want: "9090\n"},
+		{args: []string{"get", "server"}, want: "{\n  \"Port\": 9090,\n  \"Key\": \"" + configmanager.RedactedValue + "\"\n}\n"},
+		{args: []string{"get", "token"}, want: configmanager.RedactedValue + "\n"},
+		{args: []string{"set", "server.port", "0"}, wantErr: true},
+		{args: []string{"set", "server.port"}, wantErr: true},
+		{args: []string{"get", "server.unknown"}, wantErr: true},
+		{args: []string{"path"}, want: m.Path() + "\n"},
+		{args: []string{"validate"}, want: m.Path() + ": ok\n"},
+		{args: []string{"reset", "-y", "server.port"}},
+		{args: []string{"get", "server.port"}, want: "8080\n"},
+		{args: []string{"unknown"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			stdout.Reset()
+			err := cmd.Run(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := stdout.String(); got != tt.want {
+				t.Errorf("Run() output = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	stdout.Reset()
+	if err := cmd.Run([]string{"show"}); err != nil {
+		t.Fatalf("show failed: %v", err)
+	}
+	if out := stdout.String(); strings.Contains(out, "s3cr3t") || !strings.Contains(out, configmanager.RedactedValue) {
+		t.Errorf("show output is not redacted:\n%s", out)
+	}
+}
+
+func TestCommand_ResetAborted(t *testing.T) {
+	cmd, m, _ := newTestCommand(t, "n\n")
+	if err := m.SetAndSave("name", "changed"); err != nil {
+		t.Fatalf("SetAndSave() failed: %v", err)
+	}
+	if err := cmd.Run([]string{"reset"}); err == nil {
+		t.Error("reset succeeded without confirmation")
+	}
+	if got := m.Config().Name; got != "changed" {
+		t.Errorf("Config().Name = %v, want changed", got)
+	}
+}
+
+func TestCommand_ResetBrokenFile(t *testing.T) {
+	for _, content := range []string{"[server]\nport = 0\n", "[server]\nport = 1_\n"} {
+		cmd, m, _ := newTestCommand(t, "")
+		if err := os.WriteFile(m.Path(), []byte(content), 0600); err != nil {
+			t.Fatalf("failed to write config: %v", err)
+		}
+		if err := cmd.Run([]string{"reset", "-y"}); err != nil {
+			t.Fatalf("reset of %q failed: %v", content, err)
+		}
+		if err := m.Load(); err != nil {
+			t.Fatalf("Load() after reset failed: %v", err)
+		}
+		if got := m.Config(); got.Server.Port != 8080 {
+			t.Errorf("Config().Server.Port after reset = %v, want 8080", got.Server.Port)
+		}
+	}
+}
+
+func TestCommand_Edit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("editor script requires sh")
+	}
+	dir := t.TempDir()
+	counter := filepath.Join(dir, "runs")
+	editor := filepath.Join(dir, "editor.sh")
+	// first run writes invalid port, second run fixes it
+	script := "#!/bin/sh\n" +
+		"if [ -f " + counter + " ]; then port=7070; else port=0; touch " + counter + "; fi\n" +
+		"printf 'name = \"edited\"\\n[server]\\nport = %s\\n' \"$port\" > \"$1\"\n"
+	if err := os.WriteFile(editor, []byte(script), 0755); err != nil {
+		t.Fatalf("failed to write editor: %v", err)
+	}
+
+	cmd, m, _ := newTestCommand(t, "\n", WithEditor(editor))
+	if err := cmd.Run([]string{"edit"}); err != nil {
+		t.Fatalf("edit failed: %v", err)
+	}
+	got := m.Config()
+	if got.Name != "edited" || got.Server.Port != 7070 {
+		t.Errorf("Config() = %+v, want edited values", got)
+	}
+
+	os.Remove(counter)
+	cmd, _, _ = newTestCommand(t, "n\n", WithEditor(editor))
+	if err := cmd.Run([]string{"edit"}); err == nil {
+		t.Error("edit of invalid config succeeded")
+	}
+}
+
+func TestCommand_MemFS(t *testing.T) {
+	fsys := configmanager.NewMemFS()
+	path := filepath.Join("/etc", "testapp", "config.toml")
+	cfg := testConfig{Name: "default"}
+	cfg.Server.Port = 8080
+	m, err := configmanager.New("testapp", cfg, configmanager.WithFS(fsys), configmanager.ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	cmd := New(m, WithIO(strings.NewReader(""), stdout, &bytes.Buffer{}))
+
+	if err := cmd.Run([]string{"get", "server.port"}); err != nil {
+		t.Fatalf("get without config file failed: %v", err)
+	}
+	if got := stdout.String(); got != "8080\n" {
+		t.Errorf("get output = %q, want default port", got)
+	}
+	if err := fsys.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatalf("failed to create config directory: %v", err)
+	}
+	if err := fsys.WriteFile(path, []byte("[server]\nport = 0\n"), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if err := cmd.Run([]string{"get", "server.port"}); err == nil {
+		t.Error("get of invalid config file succeeded")
+	}
+}
@@ -0,0 +1,193 @@
+package configmanager
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ExportAs writes config file content serialized in format to w, the way Save
+// would write it: only the user layer is exported, without values from
+// environment, overrides or other files, secret fields are encrypted and
+// unchanged templates are kept unexpanded
+func (m *Manager[T]) ExportAs(format SerializationFormat, w io.Writer) error {
+	if err := validateFormat(format); err != nil {
+		return err
+	}
+	// write lock is needed as encryption of secrets may create key file
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, err := m.render(m.base, m.owned, format, false)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to export config: %v", err)
+	}
+	return nil
+}
+
+// Convert rewrites config file and profile files next to it into format, moves
+// them to files with matching extension and updates Path(). Values missing in
+// the config file are filled with defaults, include directive is kept.
+// Existing file with a new name is never overwritten. Managers created later
+// must use the new format or WithAutoFormat to find converted file.
+func (m *Manager[T]) Convert(format SerializationFormat) error {
+	if err := validateFormat(format); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	unlock, err := m.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if format == m.format {
+		return nil
+	}
+	profiles, err := m.profileFiles()
+	if err != nil {
+		return err
+	}
+	newPath := convertedPath(m.path, format)
+	for _, path := range append([]string{m.path}, profiles...) {
+		if target := convertedPath(path, format); fileExists(m.fs, target) {
+			return fmt.Errorf("can not convert to %v: %v already exists", format, target)
+		}
+	}
+
+	files := []convertedFile{}
+	data, err := m.fs.ReadFile(m.path)
+	switch {
+	case err == nil:
+		out, err := m.convertUserFile(format, data)
+		if err != nil {
+			return err
+		}
+		files = append(files, convertedFile{old: m.path, new: newPath, data: out})
+	case !os.IsNotExist(err):
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	for _, path := range profiles {
+		raw, err := m.fs.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read profile file: %v", err)
+		}
+		out, err := convertDoc(m.layerFormat(path, raw), format, raw)
+		if err != nil {
+			return fmt.Errorf("failed to convert %v: %v", path, err)
+		}
+		files = append(files, convertedFile{old: path, new: convertedPath(path, format), data: out})
+	}
+	if len(files) > 0 && files[0].old == m.path {
+		if err := m.backup(files[0].data); err != nil {
+			return err
+		}
+	}
+	for i, file := range files {
+		if err := m.fs.WriteFile(file.new, file.data, m.filePerm()); err != nil {
+			removeConverted(m.fs, files[:i])
+			return fmt.Errorf("failed to save converted config: %v", err)
+		}
+	}
+	var removeErr error
+	for i, file := range files {
+		if err := m.fs.Remove(file.old); err != nil {
+			if file.old == m.path {
+				removeConverted(m.fs, files)
+				return fmt.Errorf("failed to remove %v: %v", file.old, err)
+			}
+			if removeErr == nil {
+				removeErr = fmt.Errorf("config converted but failed to remove %v: %v", file.old, err)
+			}
+			continue
+		}
+		if i == 0 && file.old == m.path {
+			m.checksum = checksum(file.data)
+		}
+	}
+	m.removeLockFile()
+	m.path, m.format = newPath, format
+	return removeErr
+}
+
+// convertedFile is a config file rewritten by Convert
+type convertedFile struct {
+	old  string
+	new  string
+	data []byte
+}
+
+// convertUserFile returns content of config file data converted into format
+func (m *Manager[T]) convertUserFile(format SerializationFormat, data []byte) ([]byte, error) {
+	if err := m.checkConflict(); err != nil {
+		return nil, err
+	}
+	oldFormat := m.layerFormat(m.path, data)
+	cfg := cloneConfig(m.defaults)
+	if err := decode(oldFormat, data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to decode config file: %v", err)
+	}
+	patterns, err := includePatterns(oldFormat, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read include directive: %v", err)
+	}
+	var v any = cfg
+	if !m.ownsAllKeys() {
+		keys, err := documentKeys(oldFormat, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode config file: %v", err)
+		}
+		v = ownedValue(reflect.ValueOf(cfg).Elem(), keys, oldFormat).Interface()
+	}
+	out, err := encode(format, v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal data: %v", err)
+	}
+	if out, err = addIncludes(format, patterns, annotate[T](format, out)); err != nil {
+		return nil, fmt.Errorf("failed to keep include directive: %v", err)
+	}
+	return out, nil
+}
+
+// profileFiles returns existing profile files of config path
+func (m *Manager[T]) profileFiles() ([]string, error) {
+	ext := filepath.Ext(m.path)
+	pattern := strings.TrimSuffix(m.path, ext) + ".*" + ext
+	matches, err := glob(m.fs, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find profile files: %v", err)
+	}
+	profiles := []string{}
+	for _, match := range matches {
+		if match != m.path && fileExists(m.fs, match) {
+			profiles = append(profiles, match)
+		}
+	}
+	return profiles, nil
+}
+
+// convertedPath returns path with extension of format
+func convertedPath(path string, format SerializationFormat) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + formatExtension(format)
+}
+
+// convertDoc re-encodes raw document data from one format into another
+func convertDoc(from, to SerializationFormat, data []byte) ([]byte, error) {
+	doc := make(map[string]any)
+	if err := decode(from, data, &doc); err != nil {
+		return nil, err
+	}
+	return encode(to, doc)
+}
+
+// removeConverted removes files written by Convert
+func removeConverted(fsys FS, files []convertedFile) {
+	for _, file := range files {
+		fsys.Remove(file.new)
+	}
+}
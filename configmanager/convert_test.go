@@ -0,0 +1,164 @@
+package configmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportAs(t *testing.T) {
+	m, err := New("testapp", defaultTestConfig(), ForcePath(filepath.Join(t.TempDir(), "config.toml")))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Set("server.port", "9090"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	for _, format := range []SerializationFormat{JSON, YAML, TOML, INI} {
+		t.Run(string(format), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := m.ExportAs(format, &out); err != nil {
+				t.Fatalf("ExportAs() failed: %v", err)
+			}
+			got := testConfig{}
+			if err := decode(format, out.Bytes(), &got); err != nil {
+				t.Fatalf("exported %v is invalid: %v\n%s", format, err, out.String())
+			}
+			if got.Server.Port != 9090 || got.Name != "default" {
+				t.Errorf("exported config = %+v", got)
+			}
+		})
+	}
+	if err := m.ExportAs("xml", &bytes.Buffer{}); err == nil {
+		t.Error("ExportAs() of unknown format succeeded")
+	}
+}
+
+func TestExportAs_UserLayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, path, "name = \"user\"\n")
+	t.Setenv("TESTAPP_PORT", "99")
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Override("debug", "true"); err != nil {
+		t.Fatalf("Override() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config(); got.Server.Port != 99 || !got.Debug {
+		t.Fatalf("Config() = %+v, want env and override values", got)
+	}
+	var out bytes.Buffer
+	if err := m.ExportAs(YAML, &out); err != nil {
+		t.Fatalf("ExportAs() failed: %v", err)
+	}
+	got := testConfig{}
+	if err := decode(YAML, out.Bytes(), &got); err != nil {
+		t.Fatalf("exported YAML is invalid: %v\n%s", err, out.String())
+	}
+	if got.Name != "user" || got.Server.Port != 8080 || got.Debug {
+		t.Errorf("exported config = %+v, want user layer only", got)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	writeTestFile(t, path, "include = [\"conf.d/*.toml\"]\nname = \"user\"\n\n[server]\nport = 1\ntimeout = 5000000000\n")
+	writeTestFile(t, filepath.Join(dir, "conf.d", "10-debug.toml"), "debug = true\n")
+
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if err := m.Convert(YAML); err != nil {
+		t.Fatalf("Convert() failed: %v", err)
+	}
+	wantPath := filepath.Join(dir, "config.yaml")
+	if m.Path() != wantPath {
+		t.Errorf("Path() = %v, want %v", m.Path(), wantPath)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("old config file was not removed: %v", err)
+	}
+	data, err := os.ReadFile(wantPath)
+	if err != nil {
+		t.Fatalf("failed to read converted file: %v", err)
+	}
+	if !strings.Contains(string(data), "include:") || strings.Contains(string(data), "debug: true") {
+		t.Errorf("converted file should keep include and not inline fragments:\n%s", data)
+	}
+
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() of converted file failed: %v", err)
+	}
+	got := m.Config()
+	if got.Name != "user" || got.Server.Port != 1 || got.Server.Timeout != 5*time.Second || !got.Debug {
+		t.Errorf("Config() after convert = %+v", got)
+	}
+
+	if err := m.Convert(JSON); err != nil {
+		t.Fatalf("Convert() failed: %v", err)
+	}
+	doc := map[string]any{}
+	data, _ = os.ReadFile(filepath.Join(dir, "config.json"))
+	if err := json.Unmarshal(data, &doc); err != nil || doc["name"] != "user" {
+		t.Errorf("converted JSON = %v, %v:\n%s", doc, err, data)
+	}
+
+	writeTestFile(t, filepath.Join(dir, "config.toml"), "name = \"other\"\n")
+	if err := m.Convert(TOML); err == nil {
+		t.Error("Convert() overwrote existing file")
+	}
+}
+
+func TestConvert_Profile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	writeTestFile(t, path, "name = \"user\"\n")
+	writeTestFile(t, filepath.Join(dir, "config.prod.toml"), "[server]\nport = 443\n")
+	writeTestFile(t, filepath.Join(dir, "config.dev.toml"), "debug = true\n")
+
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithProfile("prod"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if err := m.Convert(YAML); err != nil {
+		t.Fatalf("Convert() failed: %v", err)
+	}
+	for _, name := range []string{"config.toml", "config.prod.toml", "config.dev.toml", "config.toml.lock"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%v was not removed: %v", name, err)
+		}
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() of converted files failed: %v", err)
+	}
+	if got := m.Config(); got.Name != "user" || got.Server.Port != 443 {
+		t.Errorf("Config() after convert = %+v", got)
+	}
+
+	dev, err := New("testapp", defaultTestConfig(), ForcePath(m.Path()), WithAutoFormat(), WithProfile("dev"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := dev.Load(); err != nil {
+		t.Fatalf("Load() of converted dev profile failed: %v", err)
+	}
+	if !dev.Config().Debug {
+		t.Errorf("Config().Debug of dev profile = false, want true")
+	}
+}
@@ -0,0 +1,88 @@
+package configmanager
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// dotEnvNesting separates keys of nested sections in dotenv files (SERVER__PORT)
+const dotEnvNesting = "__"
+
+// dotEnvCodec handles dotenv files of KEY=value lines. Field keys are written in
+// upper case and matched case insensitively, map keys are kept as is. Optional
+// `export` prefix is allowed.
+type dotEnvCodec struct{}
+
+func (dotEnvCodec) Marshal(v any) ([]byte, error) {
+	var out bytes.Buffer
+	if err := writeDotEnv(&out, reflect.ValueOf(v), ""); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (dotEnvCodec) Unmarshal(data []byte, v any) error {
+	doc, err := parseDotEnv(data)
+	if err != nil {
+		return err
+	}
+	return unmarshalDoc(doc, v, DotEnv)
+}
+
+// writeDotEnv writes keys of v flattening nested sections with prefix
+func writeDotEnv(out *bytes.Buffer, v reflect.Value, prefix string) error {
+	isStruct := indirect(v).Kind() == reflect.Struct
+	for _, entry := range docEntries(v, DotEnv) {
+		name := entry.key
+		if isStruct {
+			name = strings.ToUpper(name)
+		} else if name == "" || strings.Contains(name, dotEnvNesting) || strings.ContainsAny(name, "=#\"' \t\n") {
+			return fmt.Errorf("map key %q can not be written to dotenv file", prefix+name)
+		}
+		key := prefix + name
+		if isSection(entry.value) {
+			if err := writeDotEnv(out, entry.value, key+dotEnvNesting); err != nil {
+				return err
+			}
+			continue
+		}
+		text, err := scalarText(entry.value)
+		if err != nil {
+			return fmt.Errorf("%v: %v", key, err)
+		}
+		fmt.Fprintf(out, "%v=%v\n", key, quoteLineValue(text, "# \t$"))
+	}
+	return nil
+}
+
+// parseDotEnv parses dotenv data into document of nested sections and string values
+func parseDotEnv(data []byte) (map[string]any, error) {
+	doc := make(map[string]any)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %v: expected KEY=value", n+1)
+		}
+		parsed, err := parseLineValue(strings.TrimSpace(value), "#")
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", n+1, err)
+		}
+		names := strings.Split(key, dotEnvNesting)
+		section := doc
+		for _, name := range names[:len(names)-1] {
+			if section, err = childSection(section, name); err != nil {
+				return nil, fmt.Errorf("line %v: %v", n+1, err)
+			}
+		}
+		section[names[len(names)-1]] = parsed
+	}
+	return doc, nil
+}
@@ -0,0 +1,365 @@
+package configmanager
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+)
+
+// fieldKey returns the serialization key of a struct field for the given format.
+// Second value is false if the field is skipped by its tag or unexported.
+func fieldKey(f reflect.StructField, format SerializationFormat) (string, bool) {
+	if !f.IsExported() {
+		return "", false
+	}
+	tag := f.Tag.Get(tagName(format))
+	name := strings.Split(tag, ",")[0]
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return name, true
+	}
+}
+
+// splitKeyPath splits dotted key path into its segments
+func splitKeyPath(keyPath string) ([]string, error) {
+	if keyPath == "" {
+		return nil, fmt.Errorf("key path is empty")
+	}
+	keys := strings.Split(keyPath, ".")
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("key path '%v' contains empty segment", keyPath)
+		}
+	}
+	return keys, nil
+}
+
+// keyField is key of struct field, index leads through inlined embedded structs
+type keyField struct {
+	key   string
+	index []int
+}
+
+// keyFields returns keyed fields of struct t in declaration order. Fields of
+// inlined embedded structs are promoted as the codecs of format do, fields of
+// t shadow promoted fields with the same key.
+func keyFields(t reflect.Type, format SerializationFormat) []keyField {
+	fields := []keyField{}
+	direct := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		if key, ok := fieldKey(t.Field(i), format); ok && !inlined(t.Field(i), format) {
+			direct[strings.ToLower(key)] = true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !inlined(field, format) {
+			if key, ok := fieldKey(field, format); ok {
+				fields = append(fields, keyField{key: key, index: []int{i}})
+			}
+			continue
+		}
+		embedded := field.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		for _, promoted := range keyFields(embedded, format) {
+			if !direct[strings.ToLower(promoted.key)] {
+				promoted.index = append([]int{i}, promoted.index...)
+				fields = append(fields, promoted)
+			}
+		}
+	}
+	return fields
+}
+
+// inlined reports whether keys of embedded struct field are promoted to its
+// parent. YAML inlines only fields tagged `inline`, other formats inline
+// embedded structs without key. Pointers to unexported structs can not be
+// allocated, so they are never inlined.
+func inlined(f reflect.StructField, format SerializationFormat) bool {
+	t := f.Type
+	if t.Kind() == reflect.Pointer {
+		if !f.IsExported() {
+			return false
+		}
+		t = t.Elem()
+	}
+	if !f.Anonymous || t.Kind() != reflect.Struct || marshalsItself(t) {
+		return false
+	}
+	name, options, _ := strings.Cut(f.Tag.Get(tagName(format)), ",")
+	if format == YAML {
+		return slices.Contains(strings.Split(options, ","), "inline")
+	}
+	return name == ""
+}
+
+// findField returns index of struct field matching key (case insensitive)
+func findField(t reflect.Type, key string, format SerializationFormat) ([]int, bool) {
+	for _, field := range keyFields(t, format) {
+		if strings.EqualFold(field.key, key) {
+			return field.index, true
+		}
+	}
+	return nil, false
+}
+
+// fieldByIndex returns nested field of struct v. Nil embedded pointers are
+// allocated if alloc is set, otherwise invalid value is returned for them.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for n, i := range index {
+		if n > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// getPath walks v by key segments and returns the value found at the end of the path
+func getPath(v reflect.Value, keys []string, format SerializationFormat) (reflect.Value, error) {
+	if len(keys) == 0 {
+		return v, nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return reflect.Value{}, fmt.Errorf("key '%v' is not set", keys[0])
+		}
+		return getPath(v.Elem(), keys, format)
+	case reflect.Struct:
+		index, ok := findField(v.Type(), keys[0], format)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown key '%v'", keys[0])
+		}
+		field := fieldByIndex(v, index, false)
+		if !field.IsValid() {
+			return reflect.Value{}, fmt.Errorf("key '%v' is not set", keys[0])
+		}
+		return getPath(field, keys[1:], format)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return reflect.Value{}, fmt.Errorf("key '%v': map keys are not strings", keys[0])
+		}
+		elem := v.MapIndex(reflect.ValueOf(keys[0]).Convert(v.Type().Key()))
+		if !elem.IsValid() {
+			return reflect.Value{}, fmt.Errorf("key '%v' is not set", keys[0])
+		}
+		return getPath(elem, keys[1:], format)
+	default:
+		return reflect.Value{}, fmt.Errorf("key '%v': %v value has no fields", keys[0], v.Kind())
+	}
+}
+
+// setPath walks v by key segments, allocating nil pointers and maps on the way,
+// and sets the value at the end of the path
+func setPath(v reflect.Value, keys []string, value any, format SerializationFormat) error {
+	if len(keys) == 0 {
+		return setValue(v, value)
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setPath(v.Elem(), keys, value, format)
+	case reflect.Struct:
+		index, ok := findField(v.Type(), keys[0], format)
+		if !ok {
+			return fmt.Errorf("unknown key '%v'", keys[0])
+		}
+		return setPath(fieldByIndex(v, index, true), keys[1:], value, format)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("key '%v': map keys are not strings", keys[0])
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		mapKey := reflect.ValueOf(keys[0]).Convert(v.Type().Key())
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if current := v.MapIndex(mapKey); current.IsValid() {
+			elem.Set(current)
+		}
+		if err := setPath(elem, keys[1:], value, format); err != nil {
+			return err
+		}
+		v.SetMapIndex(mapKey, elem)
+		return nil
+	default:
+		return fmt.Errorf("key '%v': %v value has no fields", keys[0], v.Kind())
+	}
+}
+
+// setValue assigns value to v. Strings are converted to the type of v,
+// other values must be assignable or convertible to it.
+func setValue(v reflect.Value, value any) error {
+	if raw, ok := value.(string); ok {
+		return setFromString(v, raw)
+	}
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	switch {
+	case rv.Type().AssignableTo(v.Type()):
+		v.Set(rv)
+	case rv.Type().ConvertibleTo(v.Type()) && rv.Kind() != reflect.String && v.Kind() != reflect.String:
+		v.Set(rv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("can not assign %T to %v", value, v.Type())
+	}
+	return nil
+}
+
+// setFromString converts raw string to the type of v and assigns it
+func setFromString(v reflect.Value, raw string) error {
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(raw))
+	}
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration '%v': %v", raw, err)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(v.Type().Elem())
+		if err := setFromString(elem.Elem(), raw); err != nil {
+			return err
+		}
+		v.Set(elem)
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Interface:
+		if v.NumMethod() != 0 {
+			return fmt.Errorf("can not set %v value from string", v.Type())
+		}
+		v.Set(reflect.ValueOf(raw))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid bool '%v'", raw)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(raw, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer '%v'", raw)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(raw, 0, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer '%v'", raw)
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid float '%v'", raw)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		items := splitList(raw)
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setFromString(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("can not set %v value from string", v.Kind())
+	}
+	return nil
+}
+
+// splitList splits comma separated value into trimmed items
+func splitList(raw string) []string {
+	if strings.TrimSpace(raw) == "" {
+		return []string{}
+	}
+	items := strings.Split(raw, ",")
+	for i, item := range items {
+		items[i] = strings.TrimSpace(item)
+	}
+	return items
+}
+
+// cloneValue returns a deep copy of v so that maps, slices and pointers are not shared
+func cloneValue(v reflect.Value) reflect.Value {
+	out := reflect.New(v.Type()).Elem()
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			elem := cloneValue(v.Elem())
+			ptr := reflect.New(elem.Type())
+			ptr.Elem().Set(elem)
+			out.Set(ptr)
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			out.Set(cloneValue(v.Elem()))
+		}
+	case reflect.Struct:
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			out.Field(i).Set(cloneValue(v.Field(i)))
+		}
+	case reflect.Map:
+		if !v.IsNil() {
+			m := reflect.MakeMapWithSize(v.Type(), v.Len())
+			iter := v.MapRange()
+			for iter.Next() {
+				m.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+			}
+			out.Set(m)
+		}
+	case reflect.Slice:
+		if !v.IsNil() {
+			s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			for i := 0; i < v.Len(); i++ {
+				s.Index(i).Set(cloneValue(v.Index(i)))
+			}
+			out.Set(s)
+		}
+	default:
+		out.Set(v)
+	}
+	return out
+}
+
+// cloneConfig returns a deep copy of the config
+func cloneConfig[T any](cfg *T) *T {
+	out := new(T)
+	reflect.ValueOf(out).Elem().Set(cloneValue(reflect.ValueOf(cfg).Elem()))
+	return out
+}
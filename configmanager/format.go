@@ -0,0 +1,160 @@
+package configmanager
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// formatExtensions maps file extensions to serialization formats
+var formatExtensions = map[string]SerializationFormat{
+	".json":  JSON,
+	".jsonc": JSON,
+	".yaml":  YAML,
+	".yml":   YAML,
+	".toml":  TOML,
+	".ini":   INI,
+	".env":   DotEnv,
+	".json5": JSON5,
+}
+
+// searchOrder defines which config file names are probed by auto format detection
+var searchOrder = []string{
+	"config.toml", "config.yaml", "config.yml", "config.json", "config.jsonc",
+	"config.json5", "config.ini", "config.env",
+}
+
+var tomlLine = regexp.MustCompile(`^(\[[^\]]+\]|[A-Za-z0-9_\-."']+\s*=)`)
+
+// WithAutoFormat option enables detection of serialization format from file
+// extension (.json, .jsonc, .yaml, .yml, .toml, .json5, .ini, .env or one of
+// registered codecs) or, if extension is unknown, from file content. Without
+// forced path the first existing config.<ext> (or <name>.<ext> set by
+// WithFileName) in the config directory is used.
+// Known extensions are probed in the order listed above.
+func WithAutoFormat() ManagerOption {
+	return func(mo *managerOptions) {
+		mo.autoFormat = true
+	}
+}
+
+// FormatFromPath returns serialization format matching file extension
+func FormatFromPath(path string) (SerializationFormat, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	format, ok := formatExtensions[strings.ToLower(filepath.Ext(path))]
+	return format, ok
+}
+
+// SniffFormat guesses serialization format from file content
+func SniffFormat(data []byte) SerializationFormat {
+	trimmed := bytes.TrimSpace(stripBOM(data))
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return JSON
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if tomlLine.MatchString(line) {
+			return TOML
+		}
+		return YAML
+	}
+	return YAML
+}
+
+// detectFormat returns format of the file at path of fsys from its extension or content
+func detectFormat(fsys FS, path string) (SerializationFormat, error) {
+	if format, ok := FormatFromPath(path); ok {
+		return format, nil
+	}
+	data, err := fsys.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("can not detect format of %v: %v", path, err)
+	}
+	return SniffFormat(data), nil
+}
+
+// searchConfigFile returns the first existing config file named fileName in dir
+// of fsys and its format
+func searchConfigFile(fsys FS, dir, fileName string) (string, SerializationFormat, bool) {
+	codecMu.RLock()
+	names := append([]string{}, searchOrder...)
+	codecMu.RUnlock()
+	for _, name := range names {
+		path := filepath.Join(dir, fileName+strings.TrimPrefix(name, "config"))
+		if fileExists(fsys, path) {
+			format, _ := FormatFromPath(path)
+			return path, format, true
+		}
+	}
+	return "", "", false
+}
+
+// layerFormat returns format used to decode config layer
+func (m *Manager[T]) layerFormat(path string, data []byte) SerializationFormat {
+	if !m.autoFormat {
+		return m.format
+	}
+	if format, ok := FormatFromPath(path); ok {
+		return format
+	}
+	return SniffFormat(data)
+}
+
+// stripBOM removes UTF-8 byte order mark
+func stripBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+}
+
+// stripJSONComments removes // and /* */ comments outside of string literals
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			switch c {
+			case '\\':
+				if i+1 < len(data) {
+					i++
+					out = append(out, data[i])
+				}
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				if data[i] == '\n' {
+					out = append(out, '\n')
+				}
+				i++
+			}
+			i++
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,99 @@
+package configmanager
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		want   SerializationFormat
+		wantOk bool
+	}{
+		{path: "config.json", want: JSON, wantOk: true},
+		{path: "config.jsonc", want: JSON, wantOk: true},
+		{path: "config.yaml", want: YAML, wantOk: true},
+		{path: "config.YML", want: YAML, wantOk: true},
+		{path: "config.toml", want: TOML, wantOk: true},
+		{path: "config.conf", wantOk: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, ok := FormatFromPath(tt.path)
+			if ok != tt.wantOk || got != tt.want {
+				t.Errorf("FormatFromPath() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want SerializationFormat
+	}{
+		{name: "json object", data: "  {\"name\": \"x\"}", want: JSON},
+		{name: "toml table", data: "# comment\n[server]\nport = 1\n", want: TOML},
+		{name: "toml key", data: "name = \"x\"\n", want: TOML},
+		{name: "yaml mapping", data: "name: x\nserver:\n  port: 1\n", want: YAML},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SniffFormat([]byte(tt.data)); got != tt.want {
+				t.Errorf("SniffFormat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		wantPort int
+	}{
+		{name: "yml extension", file: "config.yml", content: "server:\n  port: 1\n", wantPort: 1},
+		{name: "jsonc with comments", file: "config.jsonc", content: "{\n // port\n \"server\": {\"port\": 2} /* end */\n}", wantPort: 2},
+		{name: "unknown extension", file: "config.conf", content: "[server]\nport = 3\n", wantPort: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeTestFile(t, path, tt.content)
+			m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithAutoFormat())
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if got := m.Config().Server.Port; got != tt.wantPort {
+				t.Errorf("Config().Server.Port = %v, want %v", got, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestAutoFormat_Search(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	path := filepath.Join(home, ".config", "testapp", "config.yaml")
+	writeTestFile(t, path, "name: from yaml\n")
+
+	m, err := New("testapp", defaultTestConfig(), WithAutoFormat())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if m.Path() != path {
+		t.Errorf("Path() = %v, want %v", m.Path(), path)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config().Name; got != "from yaml" {
+		t.Errorf("Config().Name = %v, want 'from yaml'", got)
+	}
+}
@@ -0,0 +1,355 @@
+package configmanager
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrReadOnly is returned when config file is written to read-only filesystem
+var ErrReadOnly = errors.New("read-only filesystem")
+
+// FS is filesystem holding config files and files derived from them (backups,
+// audit log, secret key). Paths are OS paths as returned by Path().
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	// WriteFile replaces file atomically
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	MkdirAll(path string, perm fs.FileMode) error
+	Remove(name string) error
+}
+
+// appendFS is implemented by filesystems able to append to file, so that
+// processes sharing a log never overwrite entries of each other
+type appendFS interface {
+	AppendFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// appendFile appends data to file of fsys, creating it if needed. File of
+// filesystem without AppendFile is rewritten.
+func appendFile(fsys FS, name string, data []byte, perm fs.FileMode) error {
+	if a, ok := fsys.(appendFS); ok {
+		return a.AppendFile(name, data, perm)
+	}
+	current, err := fsys.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return fsys.WriteFile(name, append(current, data...), perm)
+}
+
+// WithFS option sets filesystem used instead of the OS filesystem.
+// Cross-process file locks are only used with the OS filesystem.
+func WithFS(fsys FS) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.fs = fsys
+	}
+}
+
+// OSFS returns the OS filesystem
+func OSFS() FS {
+	return osFS{}
+}
+
+// osFS is the OS filesystem
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error)       { return os.ReadFile(name) }
+func (osFS) Stat(name string) (fs.FileInfo, error)      { return os.Stat(name) }
+func (osFS) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+func (osFS) MkdirAll(path string, perm fs.FileMode) error {
+	return os.MkdirAll(path, perm)
+}
+func (osFS) Remove(name string) error { return os.Remove(name) }
+
+func (osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return atomicSave(data, name, perm)
+}
+
+// AppendFile appends data with single O_APPEND write
+func (osFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readFS is the read part of FS
+type readFS interface {
+	ReadFile(name string) ([]byte, error)
+	Stat(name string) (fs.FileInfo, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+}
+
+// ReadOnly returns filesystem reading from fsys which fails every write with ErrReadOnly
+func ReadOnly(fsys FS) FS {
+	return readOnlyFS{fsys}
+}
+
+// FromFS returns read-only filesystem reading from fsys (e.g. embed.FS).
+// Paths are converted to slash separated paths relative to root of fsys.
+func FromFS(fsys fs.FS) FS {
+	return readOnlyFS{ioFS{fsys}}
+}
+
+// readOnlyFS fails every write with ErrReadOnly
+type readOnlyFS struct {
+	readFS
+}
+
+func (readOnlyFS) WriteFile(name string, _ []byte, _ fs.FileMode) error {
+	return &fs.PathError{Op: "write", Path: name, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) MkdirAll(path string, _ fs.FileMode) error {
+	return &fs.PathError{Op: "mkdir", Path: path, Err: ErrReadOnly}
+}
+
+func (readOnlyFS) Remove(name string) error {
+	return &fs.PathError{Op: "remove", Path: name, Err: ErrReadOnly}
+}
+
+// ioFS reads from fs.FS
+type ioFS struct {
+	fsys fs.FS
+}
+
+func (f ioFS) ReadFile(name string) ([]byte, error)       { return fs.ReadFile(f.fsys, ioName(name)) }
+func (f ioFS) Stat(name string) (fs.FileInfo, error)      { return fs.Stat(f.fsys, ioName(name)) }
+func (f ioFS) ReadDir(name string) ([]fs.DirEntry, error) { return fs.ReadDir(f.fsys, ioName(name)) }
+
+// ioName converts OS path to fs.FS path
+func ioName(name string) string {
+	name = strings.TrimLeft(filepath.ToSlash(filepath.Clean(name)), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// MemFS is in-memory filesystem. Zero value is not usable, use NewMemFS.
+type MemFS struct {
+	mu    sync.RWMutex
+	files map[string]memFile
+	dirs  map[string]time.Time
+}
+
+// memFile is content of MemFS file
+type memFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMemFS creates empty in-memory filesystem
+func NewMemFS() *MemFS {
+	return &MemFS{files: make(map[string]memFile), dirs: make(map[string]time.Time)}
+}
+
+// ReadFile returns copy of file content
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	file, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return append([]byte{}, file.data...), nil
+}
+
+// Stat returns file info of file or directory
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	name = filepath.Clean(name)
+	if file, ok := m.files[name]; ok {
+		return memInfo{name: filepath.Base(name), size: int64(len(file.data)), mode: file.mode, modTime: file.modTime}, nil
+	}
+	if m.isDir(name) {
+		return memInfo{name: filepath.Base(name), mode: fs.ModeDir | 0755, modTime: m.dirs[name]}, nil
+	}
+	return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+}
+
+// ReadDir returns entries of directory sorted by name
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	name = filepath.Clean(name)
+	if !m.isDir(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := []fs.DirEntry{}
+	for path, file := range m.files {
+		if filepath.Dir(path) == name {
+			info := memInfo{name: filepath.Base(path), size: int64(len(file.data)), mode: file.mode, modTime: file.modTime}
+			entries = append(entries, fs.FileInfoToDirEntry(info))
+		}
+	}
+	for path, modTime := range m.dirs {
+		if path != name && filepath.Dir(path) == name {
+			entries = append(entries, fs.FileInfoToDirEntry(memInfo{name: filepath.Base(path), mode: fs.ModeDir | 0755, modTime: modTime}))
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// WriteFile creates or replaces file. Parent directory must exist.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if !m.isDir(filepath.Dir(name)) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+	}
+	if m.isDir(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+	}
+	m.files[name] = memFile{data: append([]byte{}, data...), mode: perm, modTime: time.Now()}
+	return nil
+}
+
+// AppendFile appends data to file, creating it if needed. Parent directory must exist.
+func (m *MemFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	file, ok := m.files[name]
+	if !ok {
+		if !m.isDir(filepath.Dir(name)) {
+			return &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+		}
+		file.mode = perm
+	}
+	file.data = append(append([]byte{}, file.data...), data...)
+	file.modTime = time.Now()
+	m.files[name] = file
+	return nil
+}
+
+// MkdirAll creates directory with its parents
+func (m *MemFS) MkdirAll(path string, _ fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir := filepath.Clean(path); !m.isDir(dir); dir = filepath.Dir(dir) {
+		if _, ok := m.files[dir]; ok {
+			return &fs.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+		m.dirs[dir] = time.Now()
+	}
+	return nil
+}
+
+// Remove removes file or empty directory
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	name = filepath.Clean(name)
+	if _, ok := m.files[name]; ok {
+		delete(m.files, name)
+		return nil
+	}
+	if _, ok := m.dirs[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	for path := range m.files {
+		if filepath.Dir(path) == name {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	for path := range m.dirs {
+		if path != name && filepath.Dir(path) == name {
+			return &fs.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+	delete(m.dirs, name)
+	return nil
+}
+
+// isDir reports whether name is existing directory. Roots always exist.
+func (m *MemFS) isDir(name string) bool {
+	_, ok := m.dirs[name]
+	return ok || name == "." || filepath.Dir(name) == name
+}
+
+// memInfo is file info of MemFS entry
+type memInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() fs.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i memInfo) Sys() any           { return nil }
+
+// glob returns files of fsys matching pattern, like filepath.Glob
+func glob(fsys FS, pattern string) ([]string, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if _, err := fsys.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+	dir, file := filepath.Split(pattern)
+	dir = filepath.Clean(dir)
+	dirs := []string{dir}
+	if hasMeta(dir) && dir != pattern {
+		var err error
+		if dirs, err = glob(fsys, dir); err != nil {
+			return nil, err
+		}
+	}
+	matches := []string{}
+	for _, dir := range dirs {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if ok, _ := filepath.Match(file, entry.Name()); ok {
+				matches = append(matches, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	return matches, nil
+}
+
+// hasMeta reports whether path contains glob pattern characters
+func hasMeta(path string) bool {
+	magic := `*?[`
+	if runtime.GOOS != "windows" {
+		magic = `*?[\`
+	}
+	return strings.ContainsAny(path, magic)
+}
+
+// fileExists checks if a regular file exists at the given path of fsys
+func fileExists(fsys FS, path string) bool {
+	info, err := fsys.Stat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode().IsRegular()
+}
@@ -0,0 +1,96 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestMemFS(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	mem := NewMemFS()
+	m, err := New("testapp", defaultTestConfig(), WithFS(mem), WithBackups(2), WithAuditLog())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.LoadOrCreate(); err != nil {
+		t.Fatalf("LoadOrCreate() failed: %v", err)
+	}
+	if err := m.SetAndSave("name", "memory"); err != nil {
+		t.Fatalf("SetAndSave() failed: %v", err)
+	}
+	if _, err := os.Stat(m.Path()); !os.IsNotExist(err) {
+		t.Errorf("config was written to disk: %v", err)
+	}
+	data, err := mem.ReadFile(m.Path())
+	if err != nil {
+		t.Fatalf("config is missing in memory: %v", err)
+	}
+	if !strings.Contains(string(data), "memory") {
+		t.Errorf("config in memory = %s", data)
+	}
+	backups, err := m.ListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Errorf("ListBackups() = %v, %v, want 1 backup", backups, err)
+	}
+	if entries, err := m.AuditLog(); err != nil || len(entries) != 2 {
+		t.Errorf("AuditLog() = %v, %v, want 2 entries", entries, err)
+	}
+
+	writeMemFile(t, mem, filepath.Join(filepath.Dir(m.Path()), "conf.d", "10-port.toml"), "[server]\nport = 1\n")
+	writeMemFile(t, mem, m.Path(), "include = \"conf.d/*.toml\"\nname = \"included\"\n")
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config(); got.Name != "included" || got.Server.Port != 1 {
+		t.Errorf("Config() = %+v, want values from memory fragments", got)
+	}
+}
+
+func TestReadOnlyFS(t *testing.T) {
+	embedded := fstest.MapFS{
+		"defaults/config.toml": {Data: []byte("name = \"embedded\"\n")},
+	}
+	diskPath := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, diskPath, "name = \"disk\"\n")
+
+	tests := []struct {
+		name string
+		fsys FS
+		path string
+		want string
+	}{
+		{name: "embed", fsys: FromFS(embedded), path: "/defaults/config.toml", want: "embedded"},
+		{name: "read-only mount", fsys: ReadOnly(OSFS()), path: diskPath, want: "disk"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New("testapp", defaultTestConfig(), WithFS(tt.fsys), ForcePath(tt.path))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if got := m.Config().Name; got != tt.want {
+				t.Errorf("Config().Name = %v, want %v", got, tt.want)
+			}
+			err = m.SetAndSave("name", "changed")
+			if err == nil || !strings.Contains(err.Error(), ErrReadOnly.Error()) {
+				t.Errorf("SetAndSave() error = %v, want read-only error", err)
+			}
+		})
+	}
+}
+
+func writeMemFile(t *testing.T, mem *MemFS, path, content string) {
+	t.Helper()
+	if err := mem.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := mem.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
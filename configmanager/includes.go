@@ -0,0 +1,161 @@
+package configmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IncludeKey is the reserved top level key listing glob patterns of config
+// fragments merged after the file. Relative patterns are resolved against
+// directory of the file containing the directive. Save keeps the directive
+// and never copies values of fragments into the file.
+const IncludeKey = "include"
+
+// DropInDir returns directory next to Path() whose config files are merged
+// after the user file in lexical order
+func (m *Manager[T]) DropInDir() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.dropInDir()
+}
+
+// dropInDir returns fragment directory of the user file
+func (m *Manager[T]) dropInDir() string {
+	return filepath.Join(filepath.Dir(m.path), m.fileName+".d")
+}
+
+// includes returns files of fsys matched by include directive of raw config
+// data read from path
+func includes(fsys FS, path string, format SerializationFormat, data []byte) ([]string, error) {
+	patterns, err := includePatterns(format, data)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+	files := []string{}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := glob(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%v: invalid include pattern '%v': %v", path, pattern, err)
+		}
+		for _, match := range matches {
+			if info, err := fsys.Stat(match); err == nil && !info.IsDir() {
+				files = append(files, match)
+			}
+		}
+	}
+	return files, nil
+}
+
+// includePatterns returns value of include directive as list of patterns.
+// Line based codecs write the list as comma separated value.
+func includePatterns(format SerializationFormat, data []byte) ([]string, error) {
+	doc, lines, err := parseLineDoc(format, data)
+	if err != nil {
+		return nil, err
+	}
+	if !lines {
+		doc = make(map[string]any)
+		if err := decode(format, data, &doc); err != nil {
+			return nil, err
+		}
+	}
+	switch value := doc[IncludeKey].(type) {
+	case nil:
+		return nil, nil
+	case string, quoted:
+		if lines {
+			return splitList(fmt.Sprint(value)), nil
+		}
+		return []string{fmt.Sprint(value)}, nil
+	case []any:
+		patterns := make([]string, 0, len(value))
+		for _, item := range value {
+			pattern, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v must be string or list of strings", IncludeKey)
+			}
+			patterns = append(patterns, pattern)
+		}
+		return patterns, nil
+	default:
+		return nil, fmt.Errorf("%v must be string or list of strings", IncludeKey)
+	}
+}
+
+// dropInFiles returns config files of dir in lexical order. Hidden files and
+// files with unknown extension are skipped.
+func dropInFiles(fsys FS, dir string) ([]string, error) {
+	entries, err := fsys.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read %v: %v", dir, err)
+	}
+	files := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if _, ok := FormatFromPath(entry.Name()); ok {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// fragmentFormat returns format of fragment file taken from its extension
+func fragmentFormat(path string, fallback SerializationFormat) SerializationFormat {
+	if format, ok := FormatFromPath(path); ok {
+		return format
+	}
+	return fallback
+}
+
+// keepIncludes adds include directive of config file to config serialized in
+// format, so that Save does not drop it
+func (m *Manager[T]) keepIncludes(format SerializationFormat, data []byte) ([]byte, error) {
+	current, err := m.fs.ReadFile(m.path)
+	if err != nil {
+		return data, nil
+	}
+	patterns, err := includePatterns(m.layerFormat(m.path, current), current)
+	if err != nil {
+		return data, nil
+	}
+	return addIncludes(format, patterns, data)
+}
+
+// addIncludes adds include directive with patterns to serialized config
+func addIncludes(format SerializationFormat, patterns []string, data []byte) ([]byte, error) {
+	if len(patterns) == 0 {
+		return data, nil
+	}
+	if format == JSON || format == JSON5 {
+		value, err := json.Marshal(patterns)
+		if err != nil {
+			return nil, err
+		}
+		body := bytes.TrimPrefix(bytes.TrimSpace(data), []byte("{"))
+		separator := ","
+		if bytes.HasPrefix(bytes.TrimSpace(body), []byte("}")) {
+			separator = ""
+		}
+		if bytes.HasPrefix(body, []byte("\n")) {
+			return []byte(fmt.Sprintf("{\n  %q: %s%s%s\n", IncludeKey, value, separator, body)), nil
+		}
+		return []byte(fmt.Sprintf("{%q:%s%s%s", IncludeKey, value, separator, body)), nil
+	}
+	directive, err := encode(format, map[string]any{IncludeKey: patterns})
+	if err != nil {
+		return nil, err
+	}
+	return append(append(directive, '\n'), data...), nil
+}
@@ -0,0 +1,124 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIncludes(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		main      string
+		fragments map[string]string
+	}{
+		{
+			name: "toml",
+			file: "config.toml",
+			main: "include = [\"conf.d/*.toml\"]\nname = \"main\"\n\n[server]\n  port = 1\n",
+			fragments: map[string]string{
+				"conf.d/10-a.toml":   "name = \"a\"\n[server]\nport = 2\n",
+				"conf.d/20-b.toml":   "name = \"b\"\n",
+				"config.d/50-d.toml": "[server]\nhost = \"dropin\"\n",
+				"config.d/notes.txt": "ignored",
+			},
+		},
+		{
+			name: "yaml",
+			file: "config.yaml",
+			main: "include: conf.d/*.yaml\nname: main\nserver:\n  port: 1\n",
+			fragments: map[string]string{
+				"conf.d/10-a.yaml":   "name: a\nserver:\n  port: 2\n",
+				"conf.d/20-b.yaml":   "name: b\n",
+				"config.d/50-d.yaml": "server:\n  host: dropin\n",
+			},
+		},
+		{
+			name: "json",
+			file: "config.json",
+			main: "{\"include\": [\"conf.d/*.json\"], \"name\": \"main\", \"server\": {\"port\": 1}}\n",
+			fragments: map[string]string{
+				"conf.d/10-a.json":   "{\"name\": \"a\", \"server\": {\"port\": 2}}",
+				"conf.d/20-b.json":   "{\"name\": \"b\"}",
+				"config.d/50-d.json": "{\"server\": {\"host\": \"dropin\"}}",
+			},
+		},
+		{
+			name: "ini",
+			file: "config.ini",
+			main: "include = conf.d/*.ini,extra/*.ini\nname = main\n\n[server]\nport = 1\n",
+			fragments: map[string]string{
+				"conf.d/10-a.ini":   "name = a\n[server]\nport = 2\n",
+				"extra/20-b.ini":    "name = b\n",
+				"config.d/50-d.ini": "[server]\nhost = dropin\n",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+			writeTestFile(t, path, tt.main)
+			for name, content := range tt.fragments {
+				writeTestFile(t, filepath.Join(dir, name), content)
+			}
+			m, err := New("testapp", defaultTestConfig(), ForcePath(path), WithAutoFormat(), WithUnknownKeys(UnknownKeysReject))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			cfg := m.Config()
+			if cfg.Name != "b" || cfg.Server.Port != 2 || cfg.Server.Host != "dropin" || cfg.Server.Timeout != time.Second {
+				t.Errorf("Config() = %+v", cfg)
+			}
+
+			if err := m.Save(); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(data), "conf.d/*.") {
+				t.Errorf("Save() dropped include directive:\n%s", data)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() after Save() failed: %v", err)
+			}
+			if got := m.Config(); got.Name != "b" {
+				t.Errorf("Config().Name after Save() = %v, want b", got.Name)
+			}
+
+			for name := range tt.fragments {
+				if err := os.Remove(filepath.Join(dir, name)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() without fragments failed: %v", err)
+			}
+			if got := m.Config(); got.Name != "main" || got.Server.Port != 1 || got.Server.Host != "localhost" {
+				t.Errorf("Config() without fragments = %+v, Save() copied fragment values to main file", got)
+			}
+		})
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	writeTestFile(t, path, "include = \"extra.toml\"\n")
+	writeTestFile(t, filepath.Join(dir, "extra.toml"), "include = \"config.toml\"\n")
+	m, err := New("testapp", defaultTestConfig(), ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Fatalf("Load() error = %v, want include cycle", err)
+	}
+}
@@ -0,0 +1,177 @@
+package configmanager
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// iniCodec handles INI files. Sections ([server], [server.tls]) hold nested
+// keys, values are typed by fields of target and slices are comma separated.
+type iniCodec struct{}
+
+func (iniCodec) Marshal(v any) ([]byte, error) {
+	var out bytes.Buffer
+	if err := writeINI(&out, reflect.ValueOf(v), nil); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (iniCodec) Unmarshal(data []byte, v any) error {
+	doc, err := parseINI(data)
+	if err != nil {
+		return err
+	}
+	return unmarshalDoc(doc, v, INI)
+}
+
+// writeINI writes keys of v followed by its sections
+func writeINI(out *bytes.Buffer, v reflect.Value, section []string) error {
+	entries := docEntries(v, INI)
+	for _, entry := range entries {
+		if isSection(entry.value) {
+			continue
+		}
+		text, err := scalarText(entry.value)
+		if err != nil {
+			return fmt.Errorf("%v: %v", entry.key, err)
+		}
+		fmt.Fprintf(out, "%v = %v\n", entry.key, quoteLineValue(text, "#;"))
+	}
+	for _, entry := range entries {
+		if !isSection(entry.value) {
+			continue
+		}
+		name := append(append([]string{}, section...), entry.key)
+		if out.Len() > 0 {
+			out.WriteString("\n")
+		}
+		fmt.Fprintf(out, "[%v]\n", strings.Join(name, "."))
+		if err := writeINI(out, entry.value, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseINI parses INI data into document of nested sections and string values
+func parseINI(data []byte) (map[string]any, error) {
+	doc := make(map[string]any)
+	section := doc
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %v: invalid section header", n+1)
+			}
+			section = doc
+			for _, name := range strings.Split(line[1:len(line)-1], ".") {
+				var err error
+				if section, err = childSection(section, strings.TrimSpace(name)); err != nil {
+					return nil, fmt.Errorf("line %v: %v", n+1, err)
+				}
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %v: expected key = value", n+1)
+		}
+		parsed, err := parseLineValue(strings.TrimSpace(value), "#;")
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", n+1, err)
+		}
+		section[key] = parsed
+	}
+	return doc, nil
+}
+
+// childSection returns nested section of doc with given name, creating it if needed
+func childSection(doc map[string]any, name string) (map[string]any, error) {
+	if name == "" {
+		return nil, fmt.Errorf("empty section name")
+	}
+	switch child := doc[name].(type) {
+	case map[string]any:
+		return child, nil
+	case nil:
+		section := make(map[string]any)
+		doc[name] = section
+		return section, nil
+	default:
+		return nil, fmt.Errorf("key %v is not a section", name)
+	}
+}
+
+// parseLineValue parses value of key = value line. Quoted values are
+// unquoted, comments starting with one of comment characters are removed
+// from unquoted values.
+func parseLineValue(value, comments string) (any, error) {
+	if value == "" {
+		return "", nil
+	}
+	switch value[0] {
+	case '"':
+		end := 1
+		for ; end < len(value); end++ {
+			if value[end] == '\\' {
+				end++
+			} else if value[end] == '"' {
+				break
+			}
+		}
+		if end >= len(value) {
+			return nil, fmt.Errorf("unclosed quote")
+		}
+		unquoted, err := strconv.Unquote(value[:end+1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted value: %v", err)
+		}
+		if err := checkTrailing(value[end+1:], comments); err != nil {
+			return nil, err
+		}
+		return quoted(unquoted), nil
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed quote")
+		}
+		if err := checkTrailing(value[end+2:], comments); err != nil {
+			return nil, err
+		}
+		return quoted(value[1 : end+1]), nil
+	}
+	for i := 0; i < len(value); i++ {
+		if strings.IndexByte(comments, value[i]) >= 0 && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i]), nil
+		}
+	}
+	return value, nil
+}
+
+// checkTrailing verifies that only comment follows quoted value
+func checkTrailing(rest, comments string) error {
+	rest = strings.TrimSpace(rest)
+	if rest != "" && strings.IndexByte(comments, rest[0]) < 0 {
+		return fmt.Errorf("unexpected text after quoted value: %v", rest)
+	}
+	return nil
+}
+
+// quoteLineValue quotes text if it can not be written as unquoted value
+func quoteLineValue(text, comments string) string {
+	if text == "" {
+		return text
+	}
+	if strings.TrimSpace(text) != text || strings.ContainsAny(text, comments+"\"'\n\r\\") {
+		return strconv.Quote(text)
+	}
+	return text
+}
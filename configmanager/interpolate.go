@@ -0,0 +1,224 @@
+package configmanager
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Galdoba/appcontext/xdg"
+)
+
+// template is string value with references as read from config and its expansion
+type template struct {
+	raw      string
+	expanded string
+}
+
+// WithInterpolation option expands references in string values after decoding:
+// ${ENV_VAR}, ${ENV_VAR:-default}, ${server.host} (other config key) and
+// ${xdg.data} (XDG base directory: config, data, cache, state, runtime or temp).
+// Names containing a dot refer to config keys, $${ is a literal ${.
+// Save writes back unexpanded templates of values which were not changed.
+func WithInterpolation() ManagerOption {
+	return func(mo *managerOptions) {
+		mo.interpolation = true
+	}
+}
+
+// interpolator resolves references between config values
+type interpolator struct {
+	root     reflect.Value
+	format   SerializationFormat
+	raw      map[string]string
+	paths    map[string]string
+	expanded map[string]string
+	active   map[string]bool
+}
+
+// interpolate expands references in string values of cfg in place and
+// returns templates keyed by key path of expanded values
+func interpolate[T any](cfg *T, format SerializationFormat) (map[string]template, error) {
+	x := &interpolator{
+		root:     reflect.ValueOf(cfg).Elem(),
+		format:   format,
+		raw:      make(map[string]string),
+		paths:    make(map[string]string),
+		expanded: make(map[string]string),
+		active:   make(map[string]bool),
+	}
+	rewriteStrings(x.root, "", format, func(path, value string) (string, bool) {
+		if strings.Contains(value, "${") {
+			x.raw[path] = value
+			x.paths[strings.ToLower(path)] = path
+		}
+		return "", false
+	})
+	paths := make([]string, 0, len(x.raw))
+	for path := range x.raw {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if _, err := x.value(path); err != nil {
+			return nil, err
+		}
+	}
+	templates := make(map[string]template, len(x.expanded))
+	rewriteStrings(x.root, "", format, func(path, value string) (string, bool) {
+		expanded, ok := x.expanded[path]
+		if ok {
+			templates[path] = template{raw: value, expanded: expanded}
+		}
+		return expanded, ok
+	})
+	return templates, nil
+}
+
+// value returns expanded value of templated key path
+func (x *interpolator) value(path string) (string, error) {
+	if expanded, ok := x.expanded[path]; ok {
+		return expanded, nil
+	}
+	if x.active[path] {
+		return "", fmt.Errorf("reference cycle at %v", path)
+	}
+	x.active[path] = true
+	defer delete(x.active, path)
+	expanded, err := expand(x.raw[path], x.lookup)
+	if err != nil {
+		return "", fmt.Errorf("%v: %v", path, err)
+	}
+	x.expanded[path] = expanded
+	return expanded, nil
+}
+
+// lookup resolves reference name. Second value is false if name is not set.
+func (x *interpolator) lookup(name string) (string, bool, error) {
+	if !strings.Contains(name, ".") {
+		value, ok := os.LookupEnv(name)
+		return value, ok, nil
+	}
+	if dirType, ok := strings.CutPrefix(name, "xdg."); ok {
+		dir := xdg.BaseDir(dirType)
+		if dir == "" {
+			return "", false, fmt.Errorf("unknown XDG directory '%v'", dirType)
+		}
+		return dir, true, nil
+	}
+	if path, ok := x.paths[strings.ToLower(name)]; ok {
+		value, err := x.value(path)
+		return value, true, err
+	}
+	keys, err := splitKeyPath(name)
+	if err != nil {
+		return "", false, err
+	}
+	v, err := getPath(x.root, keys, x.format)
+	if err != nil {
+		return "", false, fmt.Errorf("invalid reference '%v': %v", name, err)
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		v = v.Elem()
+	}
+	return fmt.Sprint(v.Interface()), true, nil
+}
+
+// expand replaces ${name} and ${name:-default} references in s using lookup.
+// Default is used when name is not set or empty.
+func expand(s string, lookup func(name string) (string, bool, error)) (string, error) {
+	var out strings.Builder
+	rest := s
+	for {
+		start := strings.Index(rest, "${")
+		if start < 0 {
+			out.WriteString(rest)
+			return out.String(), nil
+		}
+		if start > 0 && rest[start-1] == '$' {
+			out.WriteString(rest[:start-1])
+			out.WriteString("${")
+			rest = rest[start+2:]
+			continue
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("unclosed reference in '%v'", s)
+		}
+		out.WriteString(rest[:start])
+		name, def, hasDefault := strings.Cut(rest[start+2:start+end], ":-")
+		if name == "" {
+			return "", fmt.Errorf("empty reference in '%v'", s)
+		}
+		value, found, err := lookup(name)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case hasDefault && (!found || value == ""):
+			value = def
+		case !found:
+			return "", fmt.Errorf("'%v' is not set", name)
+		}
+		out.WriteString(value)
+		rest = rest[start+end+1:]
+	}
+}
+
+// restoreTemplates puts back unexpanded templates of values which were not
+// changed since they were expanded
+func (m *Manager[T]) restoreTemplates(cfg *T) {
+	if len(m.templates) == 0 {
+		return
+	}
+	rewriteStrings(reflect.ValueOf(cfg).Elem(), "", m.format, func(path, value string) (string, bool) {
+		t, ok := m.templates[path]
+		return t.raw, ok && value == t.expanded
+	})
+}
+
+// rewriteStrings calls fn for every string value reachable from v with its key
+// path and replaces the value with result of fn when second result is true
+func rewriteStrings(v reflect.Value, path string, format SerializationFormat, fn func(path, value string) (string, bool)) {
+	switch v.Kind() {
+	case reflect.String:
+		if value, ok := fn(path, v.String()); ok && v.CanSet() {
+			v.SetString(value)
+		}
+	case reflect.Pointer:
+		if !v.IsNil() {
+			rewriteStrings(v.Elem(), path, format, fn)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		rewriteStrings(elem, path, format, fn)
+		if v.CanSet() {
+			v.Set(elem)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if key, ok := fieldKey(v.Type().Field(i), format); ok {
+				rewriteStrings(v.Field(i), joinPath(path, key), format, fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			rewriteStrings(v.Index(i), fmt.Sprintf("%v[%v]", path, i), format, fn)
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(key))
+			rewriteStrings(elem, joinPath(path, fmt.Sprint(key.Interface())), format, fn)
+			v.SetMapIndex(key, elem)
+		}
+	}
+}
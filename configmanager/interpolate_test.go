@@ -0,0 +1,119 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Galdoba/appcontext/xdg"
+)
+
+type interpolatedConfig struct {
+	Name    string            `toml:"name" yaml:"name" json:"name"`
+	Dir     string            `toml:"dir" yaml:"dir" json:"dir"`
+	Tags    []string          `toml:"tags" yaml:"tags" json:"tags"`
+	Labels  map[string]string `toml:"labels" yaml:"labels" json:"labels"`
+	Server  testServer        `toml:"server" yaml:"server" json:"server"`
+	Literal string            `toml:"literal" yaml:"literal" json:"literal"`
+}
+
+func TestExpand(t *testing.T) {
+	lookup := func(name string) (string, bool, error) {
+		switch name {
+		case "SET":
+			return "value", true, nil
+		case "EMPTY":
+			return "", true, nil
+		default:
+			return "", false, nil
+		}
+	}
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "plain", want: "plain"},
+		{input: "${SET}/x", want: "value/x"},
+		{input: "${MISSING:-fallback}", want: "fallback"},
+		{input: "${EMPTY:-fallback}", want: "fallback"},
+		{input: "${SET:-fallback}", want: "value"},
+		{input: "$${SET}", want: "${SET}"},
+		{input: "${MISSING}", wantErr: true},
+		{input: "${SET", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := expand(tt.input, lookup)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("expand(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestInterpolation(t *testing.T) {
+	t.Setenv("TESTAPP_HOST", "example.com")
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `name = "${TESTAPP_NAME:-app}"
+dir = "${xdg.data}/testapp"
+tags = ["${server.host}"]
+literal = "$${HOME}"
+
+[labels]
+url = "http://${server.host}:${server.port}"
+
+[server]
+host = "${TESTAPP_HOST}"
+port = 8080
+`
+	writeTestFile(t, path, content)
+	m, err := New("testapp", interpolatedConfig{}, ForcePath(path), WithInterpolation())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	cfg := m.Config()
+	want := interpolatedConfig{
+		Name:    "app",
+		Dir:     xdg.BaseDir("data") + "/testapp",
+		Tags:    []string{"example.com"},
+		Labels:  map[string]string{"url": "http://example.com:8080"},
+		Server:  testServer{Host: "example.com", Port: 8080},
+		Literal: "${HOME}",
+	}
+	if cfg.Name != want.Name || cfg.Dir != want.Dir || cfg.Tags[0] != want.Tags[0] ||
+		cfg.Labels["url"] != want.Labels["url"] || cfg.Server.Host != want.Server.Host || cfg.Literal != want.Literal {
+		t.Errorf("Config() = %+v, want %+v", cfg, want)
+	}
+
+	if err := m.Set("name", "changed"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := string(data)
+	for _, template := range []string{"${xdg.data}/testapp", "${TESTAPP_HOST}", "http://${server.host}:${server.port}", "$${HOME}", "'changed'"} {
+		if !strings.Contains(saved, template) {
+			t.Errorf("saved config does not contain %q:\n%v", template, saved)
+		}
+	}
+}
+
+func TestInterpolationCycle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, path, "[server]\nhost = \"${labels.host}\"\n[labels]\nhost = \"${server.host}\"\n")
+	m, err := New("testapp", interpolatedConfig{}, ForcePath(path), WithInterpolation())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Fatalf("Load() error = %v, want reference cycle", err)
+	}
+}
@@ -0,0 +1,232 @@
+package configmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// json5Codec handles JSON5 files: comments, trailing commas, unquoted keys,
+// single quoted strings and hexadecimal numbers. Files are written as plain JSON.
+type json5Codec struct{}
+
+func (json5Codec) Marshal(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (json5Codec) Unmarshal(data []byte, v any) error {
+	converted, err := json5ToJSON(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(converted, v)
+}
+
+// json5ToJSON converts JSON5 document to JSON keeping line breaks in place
+func json5ToJSON(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c == '/' && i+1 < len(data) && (data[i+1] == '/' || data[i+1] == '*'):
+			end, err := skipJSON5Comment(data, i, &out)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+		case c == '"' || c == '\'':
+			s, end, err := readJSON5String(data, i)
+			if err != nil {
+				return nil, err
+			}
+			quoted, _ := json.Marshal(s)
+			out.Write(quoted)
+			i = end
+		case c == ',':
+			next := i + 1
+			for next < len(data) {
+				if data[next] == '/' && next+1 < len(data) && (data[next+1] == '/' || data[next+1] == '*') {
+					end, err := skipJSON5Comment(data, next, &bytes.Buffer{})
+					if err != nil {
+						return nil, err
+					}
+					next = end
+				} else if strings.IndexByte(" \t\r\n", data[next]) >= 0 {
+					next++
+				} else {
+					break
+				}
+			}
+			if next >= len(data) || data[next] != '}' && data[next] != ']' {
+				out.WriteByte(',')
+			}
+			i++
+		case isJSON5IdentStart(c):
+			end := i
+			for end < len(data) && (isJSON5IdentStart(data[end]) || data[end] >= '0' && data[end] <= '9') {
+				end++
+			}
+			word := string(data[i:end])
+			switch word {
+			case "true", "false", "null":
+				out.WriteString(word)
+			case "Infinity", "NaN":
+				return nil, fmt.Errorf("line %v: %v is not supported", lineOf(data, i), word)
+			default:
+				next := end
+				for next < len(data) && strings.IndexByte(" \t\r\n", data[next]) >= 0 {
+					next++
+				}
+				if next >= len(data) || data[next] != ':' {
+					return nil, fmt.Errorf("line %v: unexpected identifier %v", lineOf(data, i), word)
+				}
+				quoted, _ := json.Marshal(word)
+				out.Write(quoted)
+			}
+			i = end
+		case c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9':
+			end := i + 1
+			for end < len(data) && strings.IndexByte("0123456789abcdefABCDEFxX.+-", data[end]) >= 0 {
+				end++
+			}
+			number, err := json5Number(string(data[i:end]))
+			if err != nil {
+				return nil, fmt.Errorf("line %v: %v", lineOf(data, i), err)
+			}
+			out.WriteString(number)
+			i = end
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// skipJSON5Comment skips comment starting at i writing its line breaks to out
+func skipJSON5Comment(data []byte, i int, out *bytes.Buffer) (int, error) {
+	if data[i+1] == '/' {
+		for i < len(data) && data[i] != '\n' {
+			i++
+		}
+		return i, nil
+	}
+	end := bytes.Index(data[i+2:], []byte("*/"))
+	if end < 0 {
+		return 0, fmt.Errorf("line %v: unclosed comment", lineOf(data, i))
+	}
+	out.Write(bytes.Repeat([]byte("\n"), bytes.Count(data[i:i+2+end], []byte("\n"))))
+	return i + 2 + end + 2, nil
+}
+
+// readJSON5String reads single or double quoted string starting at i
+func readJSON5String(data []byte, i int) (string, int, error) {
+	quote := data[i]
+	var s []rune
+	for j := i + 1; j < len(data); j++ {
+		c := data[j]
+		switch {
+		case c == quote:
+			return string(s), j + 1, nil
+		case c == '\n':
+			return "", 0, fmt.Errorf("line %v: unterminated string", lineOf(data, i))
+		case c != '\\':
+			r, size := utf8.DecodeRune(data[j:])
+			s = append(s, r)
+			j += size - 1
+			continue
+		}
+		j++
+		if j >= len(data) {
+			break
+		}
+		switch data[j] {
+		case 'n':
+			s = append(s, '\n')
+		case 't':
+			s = append(s, '\t')
+		case 'r':
+			s = append(s, '\r')
+		case 'b':
+			s = append(s, '\b')
+		case 'f':
+			s = append(s, '\f')
+		case 'v':
+			s = append(s, '\v')
+		case '0':
+			s = append(s, 0)
+		case '\r':
+			if j+1 < len(data) && data[j+1] == '\n' {
+				j++
+			}
+		case '\n':
+		case 'x', 'u':
+			size := 2
+			if data[j] == 'u' {
+				size = 4
+			}
+			if j+size >= len(data) {
+				return "", 0, fmt.Errorf("line %v: invalid escape", lineOf(data, j))
+			}
+			code, err := strconv.ParseUint(string(data[j+1:j+1+size]), 16, 32)
+			if err != nil {
+				return "", 0, fmt.Errorf("line %v: invalid escape", lineOf(data, j))
+			}
+			r := rune(code)
+			if len(s) > 0 && utf16.IsSurrogate(s[len(s)-1]) && utf16.IsSurrogate(r) {
+				r = utf16.DecodeRune(s[len(s)-1], r)
+				s = s[:len(s)-1]
+			}
+			s = append(s, r)
+			j += size
+		default:
+			s = append(s, rune(data[j]))
+		}
+	}
+	return "", 0, fmt.Errorf("line %v: unterminated string", lineOf(data, i))
+}
+
+// json5Number converts JSON5 number literal to JSON
+func json5Number(token string) (string, error) {
+	sign := ""
+	switch {
+	case strings.HasPrefix(token, "+"):
+		token = token[1:]
+	case strings.HasPrefix(token, "-"):
+		sign, token = "-", token[1:]
+	}
+	if strings.HasPrefix(token, "0x") || strings.HasPrefix(token, "0X") {
+		n, err := strconv.ParseUint(token[2:], 16, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid number %v", token)
+		}
+		return sign + strconv.FormatUint(n, 10), nil
+	}
+	if strings.HasPrefix(token, ".") {
+		token = "0" + token
+	}
+	token = strings.Replace(strings.Replace(token, ".e", ".0e", 1), ".E", ".0E", 1)
+	token = strings.TrimSuffix(token, ".")
+	if _, err := strconv.ParseFloat(token, 64); err != nil {
+		return "", fmt.Errorf("invalid number %v", token)
+	}
+	return sign + token, nil
+}
+
+// isJSON5IdentStart reports whether c can start unquoted key
+func isJSON5IdentStart(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$'
+}
+
+// lineOf returns line number of offset i
+func lineOf(data []byte, i int) int {
+	return bytes.Count(data[:i], []byte("\n")) + 1
+}
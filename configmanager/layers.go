@@ -0,0 +1,577 @@
+package configmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/Galdoba/appcontext/xdg"
+)
+
+// SourceKind describes role of config file in configuration resolution
+type SourceKind string
+
+const (
+	SourceSystem   SourceKind = "system"
+	SourceUser     SourceKind = "user"
+	SourceProfile  SourceKind = "profile"
+	SourceProject  SourceKind = "project"
+	SourceFragment SourceKind = "fragment"
+)
+
+// Source is a config file which contributed to loaded configuration
+type Source struct {
+	Path string
+	Kind SourceKind
+}
+
+// configLayer is a single file participating in configuration resolution
+type configLayer struct {
+	path     string
+	kind     SourceKind
+	optional bool
+	// merge deep merges the file into config instead of decoding it over config
+	merge bool
+	// fragment is an included or drop-in file which is never migrated
+	fragment bool
+}
+
+// WithSystemFile option adds system wide config file (e.g. /etc/<app>/config.toml)
+// which is applied after defaults and before the user file. Missing file is skipped.
+// As with WithSystemDirs, Save does not write keys missing in the user file.
+func WithSystemFile(path string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.systemPath = path
+	}
+}
+
+// WithSystemDirs option searches system config directories before the user
+// file: /etc/<app> followed by <dir>/<app> for every directory of XDG_CONFIG_DIRS
+// (/etc/xdg by default), directories listed first take precedence. User file
+// is optional if a system file was found. Save always writes the user file,
+// keeping there only keys it already has and keys changed by Set and Reset, so
+// values of system files are not hidden by copies of them or of defaults.
+func WithSystemDirs() ManagerOption {
+	return func(mo *managerOptions) {
+		mo.systemDirs = true
+	}
+}
+
+// WithProjectFile option adds project local config file which is applied
+// after the user file. Missing file is skipped.
+func WithProjectFile(path string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.projectPath = path
+	}
+}
+
+// WithEnvPrefix option enables environment overrides for fields without `env` tag.
+// Variable name is derived from prefix and key path: PREFIX_SERVER_LISTEN_ADDR.
+func WithEnvPrefix(prefix string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.envPrefix = prefix
+	}
+}
+
+// WithOverrides option sets explicit overrides (usually taken from command line flags)
+// keyed by dotted key path. Overrides are applied last.
+func WithOverrides(overrides map[string]string) ManagerOption {
+	return func(mo *managerOptions) {
+		for key, value := range overrides {
+			mo.overrides[key] = value
+		}
+	}
+}
+
+// Override sets explicit override for dotted key path. It takes effect on next Load.
+func (m *Manager[T]) Override(keyPath, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys, err := splitKeyPath(keyPath)
+	if err != nil {
+		return err
+	}
+	probe := cloneConfig(m.defaults)
+	if err := setPath(reflect.ValueOf(probe).Elem(), keys, value, m.format); err != nil {
+		return fmt.Errorf("invalid override %v: %v", keyPath, err)
+	}
+	m.overrides[keyPath] = value
+	return nil
+}
+
+// Sources returns config files applied by the last Load in order of precedence,
+// the last one wins
+func (m *Manager[T]) Sources() []Source {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Source{}, m.sources...)
+}
+
+// layers returns config files in order of resolution
+func (m *Manager[T]) layers() []configLayer {
+	layers := []configLayer{}
+	if m.systemDirs {
+		for _, dir := range systemDirs(m.appName) {
+			layers = append(layers, configLayer{path: m.systemFile(dir), kind: SourceSystem, optional: true})
+		}
+	}
+	if m.systemPath != "" {
+		layers = append(layers, configLayer{path: m.systemPath, kind: SourceSystem, optional: true})
+	}
+	layers = append(layers, configLayer{path: m.path, kind: SourceUser})
+	if m.profile != "" {
+		layers = append(layers, configLayer{path: profilePath(m.path, m.profile), kind: SourceProfile, merge: true})
+	}
+	if m.projectPath != "" {
+		layers = append(layers, configLayer{path: m.projectPath, kind: SourceProject, optional: true})
+	}
+	return layers
+}
+
+// systemDirs returns system config directories of application in order of
+// increasing precedence
+func systemDirs(appName string) []string {
+	dirs := []string{filepath.Join(string(filepath.Separator), "etc", appName)}
+	xdgDirs := xdg.ConfigDirs()
+	for i := len(xdgDirs) - 1; i >= 0; i-- {
+		dirs = append(dirs, filepath.Join(xdgDirs[i], appName))
+	}
+	return dirs
+}
+
+// systemFile returns config file of system directory named as the user file.
+// With auto format the first existing config file of the directory is used.
+func (m *Manager[T]) systemFile(dir string) string {
+	if m.autoFormat {
+		if path, _, ok := searchConfigFile(m.fs, dir, m.fileName); ok {
+			return path
+		}
+	}
+	return filepath.Join(dir, filepath.Base(m.path))
+}
+
+// resolution is the result of configuration resolution
+type resolution[T any] struct {
+	config *T
+	// base is the user file decoded over defaults, owned holds its keys
+	base       *T
+	owned      keyTree
+	systemKeys keyTree
+	userFile   []byte // content of user file as read from disk
+	migrated   bool   // user file was migrated to current schema version
+	checksum   string // checksum of user file once resolution is applied
+	warnings   []FieldError
+	// fragments lists included and drop-in files applied to config
+	fragments []string
+	// templates holds unexpanded values of interpolated keys
+	templates map[string]template
+	sources   []Source
+}
+
+// resolve builds new config from defaults, config files (system, user, profile,
+// project) with their fragments, environment and overrides.
+func (m *Manager[T]) resolve() (*resolution[T], error) {
+	res := &resolution[T]{systemKeys: keyTree{}}
+	cfg := cloneConfig(m.defaults)
+	for _, layer := range m.layers() {
+		if layer.kind == SourceUser && len(res.sources) > 0 {
+			layer.optional = true
+		}
+		if err := m.applyLayer(res, cfg, layer, make(map[string]bool)); err != nil {
+			return nil, err
+		}
+	}
+	if res.base == nil {
+		res.base, res.owned = cloneConfig(m.defaults), keyTree{}
+	}
+	for _, c := range []*T{cfg, res.base} {
+		if err := m.openSecrets(c); err != nil {
+			return nil, fmt.Errorf("failed to decrypt secrets: %v", err)
+		}
+	}
+	if err := applyEnv(reflect.ValueOf(cfg).Elem(), m.envPrefix, nil, m.format); err != nil {
+		return nil, err
+	}
+	if err := m.applyOverrides(cfg); err != nil {
+		return nil, err
+	}
+	if m.interpolation {
+		templates, err := interpolate(cfg, m.format)
+		if err != nil {
+			return nil, fmt.Errorf("failed to interpolate values: %v", err)
+		}
+		res.templates = templates
+	}
+	m.stampVersion(cfg)
+	m.stampVersion(res.base)
+	res.config = cfg
+	return res, nil
+}
+
+// applyLayer decodes config file of the layer into cfg followed by files it
+// includes and, for the user file, the drop-in directory. included tracks
+// files on the current include chain to detect cycles.
+func (m *Manager[T]) applyLayer(res *resolution[T], cfg *T, layer configLayer, included map[string]bool) error {
+	data, err := m.fs.ReadFile(layer.path)
+	if err != nil {
+		if layer.optional && os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read selected file: %v", err)
+	}
+	if layer.path == m.path {
+		res.userFile = data
+	}
+	res.sources = append(res.sources, Source{Path: layer.path, Kind: layer.kind})
+	format := m.layerFormat(layer.path, data)
+	if layer.fragment {
+		format = fragmentFormat(layer.path, format)
+		res.fragments = append(res.fragments, layer.path)
+	} else {
+		var migrated bool
+		data, migrated, err = m.migrate(format, data)
+		if err != nil {
+			return fmt.Errorf("failed to migrate %v: %v", layer.path, err)
+		}
+		if migrated && layer.path == m.path {
+			res.migrated = true
+		}
+	}
+	if m.schemaValidation {
+		if err := m.checkSchema(layer.path, format, data); err != nil {
+			return fmt.Errorf("config schema validation failed: %w", err)
+		}
+	}
+	if m.unknownKeys != UnknownKeysIgnore {
+		unknown, err := m.checkUnknownKeys(layer.path, format, data)
+		if err != nil {
+			return err
+		}
+		if len(unknown) > 0 && m.unknownKeys == UnknownKeysReject {
+			return fmt.Errorf("config contains unknown keys: %w", &ValidationError{Fields: unknown})
+		}
+		res.warnings = append(res.warnings, unknown...)
+	}
+	switch layer.kind {
+	case SourceUser:
+		res.base = cloneConfig(m.defaults)
+		if err := decode(format, data, res.base); err != nil {
+			return fmt.Errorf("failed to decode %v: %v", layer.path, err)
+		}
+		if res.owned, err = documentKeys(format, data); err != nil {
+			return fmt.Errorf("failed to decode %v: %v", layer.path, err)
+		}
+	case SourceSystem:
+		keys, err := documentKeys(format, data)
+		if err != nil {
+			return fmt.Errorf("failed to decode %v: %v", layer.path, err)
+		}
+		res.systemKeys.merge(keys)
+	}
+	if layer.merge {
+		err = m.mergeFile(format, data, cfg)
+	} else {
+		err = decode(format, data, cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %v: %v", layer.path, err)
+	}
+
+	fragments, err := includes(m.fs, layer.path, format, data)
+	if err != nil {
+		return err
+	}
+	if layer.path == m.path {
+		dropIns, err := dropInFiles(m.fs, m.dropInDir())
+		if err != nil {
+			return err
+		}
+		fragments = append(fragments, dropIns...)
+	}
+	key := filepath.Clean(layer.path)
+	included[key] = true
+	defer delete(included, key)
+	for _, fragment := range fragments {
+		if included[filepath.Clean(fragment)] {
+			return fmt.Errorf("include cycle: %v includes %v", layer.path, fragment)
+		}
+		if err := m.applyLayer(res, cfg, configLayer{path: fragment, kind: SourceFragment, merge: true, fragment: true}, included); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyOverrides sets explicit overrides in lexical order of their key paths
+func (m *Manager[T]) applyOverrides(cfg *T) error {
+	keyPaths := make([]string, 0, len(m.overrides))
+	for keyPath := range m.overrides {
+		keyPaths = append(keyPaths, keyPath)
+	}
+	sort.Strings(keyPaths)
+	for _, keyPath := range keyPaths {
+		keys, err := splitKeyPath(keyPath)
+		if err != nil {
+			return err
+		}
+		if err := setPath(reflect.ValueOf(cfg).Elem(), keys, m.overrides[keyPath], m.format); err != nil {
+			return fmt.Errorf("override %v: %v", keyPath, err)
+		}
+	}
+	return nil
+}
+
+// applyEnv walks struct fields and sets values from environment variables
+// named by `env` tag or derived from prefix and key path
+func applyEnv(v reflect.Value, prefix string, keys []string, format SerializationFormat) error {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key, ok := fieldKey(field, format)
+		if !ok {
+			continue
+		}
+		fieldKeys := append(append([]string{}, keys...), key)
+		name := field.Tag.Get("env")
+		if name == "" && prefix != "" {
+			name = envName(prefix, fieldKeys)
+		}
+		if value, found := os.LookupEnv(name); name != "" && found {
+			if err := setFromString(v.Field(i), value); err != nil {
+				return fmt.Errorf("env %v: %v", name, err)
+			}
+			continue
+		}
+		if err := applyEnv(v.Field(i), prefix, fieldKeys, format); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envName derives environment variable name from prefix and key path
+func envName(prefix string, keys []string) string {
+	parts := append([]string{prefix}, keys...)
+	name := strings.ToUpper(strings.Join(parts, "_"))
+	return strings.NewReplacer("-", "_", ".", "_").Replace(name)
+}
+
+// ownsAllKeys reports whether Save writes every key to the user file. Keys
+// written to the user file hide values of system files, so with system files
+// only keys present in the user file or set by Set and Reset are written.
+func (m *Manager[T]) ownsAllKeys() bool {
+	return m.systemPath == "" && !m.systemDirs
+}
+
+// keyTree is a set of key paths of config document. Nil subtree holds every
+// key below its key.
+type keyTree map[string]keyTree
+
+// documentKeys returns keys of raw config data
+func documentKeys(format SerializationFormat, data []byte) (keyTree, error) {
+	doc := make(map[string]any)
+	if err := decode(format, data, &doc); err != nil {
+		return nil, err
+	}
+	return docKeys(doc), nil
+}
+
+// docKeys returns keys of decoded document
+func docKeys(doc map[string]any) keyTree {
+	tree := make(keyTree, len(doc))
+	for key, value := range doc {
+		tree[key] = nil
+		if nested, ok := value.(map[string]any); ok {
+			tree[key] = docKeys(nested)
+		}
+	}
+	return tree
+}
+
+// child returns subtree of key. Struct fields are matched case insensitively,
+// so exact match is preferred.
+func (t keyTree) child(key string) (keyTree, bool) {
+	if sub, ok := t[key]; ok {
+		return sub, true
+	}
+	for name, sub := range t {
+		if strings.EqualFold(name, key) {
+			return sub, true
+		}
+	}
+	return nil, false
+}
+
+// add puts key path with every key below it into tree
+func (t keyTree) add(keys []string) {
+	if len(keys) == 1 {
+		t[keys[0]] = nil
+		return
+	}
+	sub, ok := t[keys[0]]
+	if ok && sub == nil {
+		return
+	}
+	if !ok {
+		sub = keyTree{}
+		t[keys[0]] = sub
+	}
+	sub.add(keys[1:])
+}
+
+// merge adds key paths of other to tree. Returns true if tree was changed.
+func (t keyTree) merge(other keyTree) bool {
+	changed := false
+	for key, sub := range other {
+		current, ok := t[key]
+		switch {
+		case !ok:
+			t[key] = sub.clone()
+			changed = true
+		case current == nil:
+		case sub == nil:
+			t[key] = nil
+			changed = true
+		default:
+			changed = current.merge(sub) || changed
+		}
+	}
+	return changed
+}
+
+// without returns copy of tree without key paths of other
+func (t keyTree) without(other keyTree) keyTree {
+	out := make(keyTree, len(t))
+	for key, sub := range t {
+		otherSub, ok := other.child(key)
+		switch {
+		case !ok:
+			out[key] = sub.clone()
+		case otherSub == nil || sub == nil:
+		default:
+			if rest := sub.without(otherSub); len(rest) > 0 {
+				out[key] = rest
+			}
+		}
+	}
+	return out
+}
+
+// clone returns deep copy of tree
+func (t keyTree) clone() keyTree {
+	if t == nil {
+		return nil
+	}
+	out := make(keyTree, len(t))
+	for key, sub := range t {
+		out[key] = sub.clone()
+	}
+	return out
+}
+
+// canonicalKeys replaces segments of key path naming struct fields with their
+// serialization keys
+func canonicalKeys(t reflect.Type, keys []string, format SerializationFormat) []string {
+	out := append([]string{}, keys...)
+	for i, key := range keys {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			index, ok := findField(t, key, format)
+			if !ok {
+				return out
+			}
+			out[i], _ = fieldKey(t.FieldByIndex(index), format)
+			t = t.FieldByIndex(index).Type
+		case reflect.Map:
+			t = t.Elem()
+		default:
+			return out
+		}
+	}
+	return out
+}
+
+// ownedValue returns copy of v holding only keys of tree. Structs are rebuilt
+// from their owned fields, so that order and tags of fields are kept.
+func ownedValue(v reflect.Value, tree keyTree, format SerializationFormat) reflect.Value {
+	if tree == nil || marshalsItself(v.Type()) {
+		return v
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		return ownedValue(v.Elem(), tree, format)
+	case reflect.Struct:
+		fields := []reflect.StructField{}
+		values := []reflect.Value{}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if field.Anonymous {
+				return v
+			}
+			key, ok := fieldKey(field, format)
+			if !ok {
+				continue
+			}
+			sub, ok := tree.child(key)
+			if !ok {
+				continue
+			}
+			value := ownedValue(v.Field(i), sub, format)
+			fields = append(fields, reflect.StructField{Name: field.Name, Type: value.Type(), Tag: field.Tag})
+			values = append(values, value)
+		}
+		out := reflect.New(reflect.StructOf(fields)).Elem()
+		for i, value := range values {
+			out.Field(i).Set(value)
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return v
+		}
+		elemType := v.Type().Elem()
+		for _, sub := range tree {
+			if sub != nil {
+				elemType = reflect.TypeOf((*any)(nil)).Elem()
+				break
+			}
+		}
+		out := reflect.MakeMap(reflect.MapOf(v.Type().Key(), elemType))
+		iter := v.MapRange()
+		for iter.Next() {
+			if sub, ok := tree[iter.Key().String()]; ok {
+				out.SetMapIndex(iter.Key(), ownedValue(iter.Value(), sub, format))
+			}
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// marshalsItself reports whether values of t are serialized by their own methods
+func marshalsItself(t reflect.Type) bool {
+	for _, target := range []reflect.Type{textMarshalerType, jsonMarshalerType} {
+		if t.Implements(target) || reflect.PointerTo(t).Implements(target) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testServer struct {
+	Host    string        `toml:"host" yaml:"host" json:"host"`
+	Port    int           `toml:"port" yaml:"port" json:"port" env:"TESTAPP_PORT"`
+	Timeout time.Duration `toml:"timeout" yaml:"timeout" json:"timeout"`
+}
+
+type testConfig struct {
+	Name   string     `toml:"name" yaml:"name" json:"name"`
+	Debug  bool       `toml:"debug" yaml:"debug" json:"debug"`
+	Tags   []string   `toml:"tags" yaml:"tags" json:"tags"`
+	Server testServer `toml:"server" yaml:"server" json:"server"`
+}
+
+func defaultTestConfig() testConfig {
+	return testConfig{
+		Name:   "default",
+		Tags:   []string{"a"},
+		Server: testServer{Host: "localhost", Port: 8080, Timeout: time.Second},
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+}
+
+func TestLoad_Layers(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemPath := filepath.Join(tmpDir, "etc", "config.toml")
+	userPath := filepath.Join(tmpDir, "user", "config.toml")
+	projectPath := filepath.Join(tmpDir, "project", "config.toml")
+
+	writeTestFile(t, systemPath, "name = \"system\"\n[server]\nhost = \"0.0.0.0\"\n")
+	writeTestFile(t, userPath, "name = \"user\"\n")
+	writeTestFile(t, projectPath, "debug = true\n")
+	t.Setenv("TESTAPP_PORT", "9090")
+	t.Setenv("TESTAPP_SERVER_HOST", "example.com")
+
+	m, err := New("testapp", defaultTestConfig(),
+		ForcePath(userPath),
+		WithSystemFile(systemPath),
+		WithProjectFile(projectPath),
+		WithEnvPrefix("TESTAPP"),
+		WithOverrides(map[string]string{"server.timeout": "5s"}),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Override("tags", "x, y"); err != nil {
+		t.Fatalf("Override() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	got := m.Config()
+	want := testConfig{
+		Name:   "user",
+		Debug:  true,
+		Tags:   []string{"x", "y"},
+		Server: testServer{Host: "example.com", Port: 9090, Timeout: 5 * time.Second},
+	}
+	if got.Name != want.Name || got.Debug != want.Debug || got.Server != want.Server {
+		t.Errorf("Config() = %+v, want %+v", got, want)
+	}
+	if len(got.Tags) != 2 || got.Tags[0] != "x" || got.Tags[1] != "y" {
+		t.Errorf("Config().Tags = %v, want %v", got.Tags, want.Tags)
+	}
+}
+
+func TestLoad_MissingOptionalLayers(t *testing.T) {
+	tmpDir := t.TempDir()
+	userPath := filepath.Join(tmpDir, "config.yaml")
+	writeTestFile(t, userPath, "server:\n  port: 1234\n")
+
+	m, err := New("testapp", defaultTestConfig(),
+		ForcePath(userPath),
+		WithSerializationFormat(YAML),
+		WithSystemFile(filepath.Join(tmpDir, "missing", "config.yaml")),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	got := m.Config()
+	if got.Server.Port != 1234 || got.Server.Host != "localhost" || got.Name != "default" {
+		t.Errorf("Config() = %+v", got)
+	}
+}
+
+func TestOverride_UnknownKey(t *testing.T) {
+	m, err := New("testapp", defaultTestConfig(), ForcePath(filepath.Join(t.TempDir(), "config.toml")))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Override("server.unknown", "1"); err == nil {
+		t.Error("Override() succeeded unexpectedly")
+	}
+	if err := m.Override("server.port", "not a number"); err == nil {
+		t.Error("Override() succeeded unexpectedly")
+	}
+}
+
+func TestLoad_SystemDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+	appName := "testapp-systemdirs"
+	first := filepath.Join(tmpDir, "first")
+	second := filepath.Join(tmpDir, "second")
+	userPath := filepath.Join(tmpDir, "user", "config.toml")
+	t.Setenv("XDG_CONFIG_DIRS", first+string(filepath.ListSeparator)+second)
+
+	writeTestFile(t, filepath.Join(second, appName, "config.toml"), "name = \"second\"\ndebug = true\n")
+	writeTestFile(t, filepath.Join(first, appName, "config.toml"), "name = \"first\"\n")
+
+	m, err := New(appName, defaultTestConfig(), ForcePath(userPath), WithSystemDirs())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() without user file failed: %v", err)
+	}
+	got := m.Config()
+	if got.Name != "first" || !got.Debug {
+		t.Errorf("Config() = %+v, want name from first dir and debug from second", got)
+	}
+	want := []Source{
+		{Path: filepath.Join(second, appName, "config.toml"), Kind: SourceSystem},
+		{Path: filepath.Join(first, appName, "config.toml"), Kind: SourceSystem},
+	}
+	if sources := m.Sources(); !reflect.DeepEqual(sources, want) {
+		t.Errorf("Sources() = %v, want %v", sources, want)
+	}
+
+	if err := m.Set("name", "user"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	doc, err := m.readDoc(userPath)
+	if err != nil {
+		t.Fatalf("Save() did not write user file: %v", err)
+	}
+	if !reflect.DeepEqual(doc, map[string]any{"name": "user"}) {
+		t.Errorf("user file = %v, want only key set by Set()", doc)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config().Name; got != "user" {
+		t.Errorf("Config().Name = %v, want user", got)
+	}
+	want = append(want, Source{Path: userPath, Kind: SourceUser})
+	if sources := m.Sources(); !reflect.DeepEqual(sources, want) {
+		t.Errorf("Sources() = %v, want %v", sources, want)
+	}
+}
+
+func TestSave_WritesOnlyUserLayer(t *testing.T) {
+	tmpDir := t.TempDir()
+	systemPath := filepath.Join(tmpDir, "etc", "config.toml")
+	writeTestFile(t, systemPath, "name = \"system\"\n")
+	t.Setenv("TESTAPP_PORT", "9090")
+
+	tests := []struct {
+		name    string
+		options []ManagerOption
+		want    map[string]any
+	}{
+		{
+			name: "without system file",
+			want: map[string]any{
+				"name": "default", "debug": true, "tags": []any{"a"},
+				"server": map[string]any{"host": "h", "port": int64(8080), "timeout": int64(time.Second)},
+			},
+		},
+		{
+			name:    "with system file",
+			options: []ManagerOption{WithSystemFile(systemPath)},
+			want:    map[string]any{"debug": true, "server": map[string]any{"host": "h"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userPath := filepath.Join(t.TempDir(), "config.toml")
+			writeTestFile(t, userPath, "debug = true\n")
+			options := append([]ManagerOption{ForcePath(userPath), WithOverrides(map[string]string{"server.timeout": "5s"})}, tt.options...)
+			m, err := New("testapp", defaultTestConfig(), options...)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			if err := m.SetAndSave("server.host", "h"); err != nil {
+				t.Fatalf("SetAndSave() failed: %v", err)
+			}
+			if err := m.Save(); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			got, err := m.readDoc(userPath)
+			if err != nil {
+				t.Fatalf("failed to read saved file: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("saved user file = %v, want %v", got, tt.want)
+			}
+			if cfg := m.Config(); cfg.Server.Port != 9090 || cfg.Server.Timeout != 5*time.Second || cfg.Server.Host != "h" {
+				t.Errorf("Config() = %+v, want env, override and set values", cfg)
+			}
+		})
+	}
+}
+
+func TestLoadOrCreate_SystemDirs(t *testing.T) {
+	tmpDir := t.TempDir()
+	appName := "testapp-systemdirs"
+	userPath := filepath.Join(tmpDir, "user", "config.toml")
+	t.Setenv("XDG_CONFIG_DIRS", filepath.Join(tmpDir, "xdg"))
+	systemPath := filepath.Join(tmpDir, "xdg", appName, "config.toml")
+	writeTestFile(t, systemPath, "name = \"system\"\n")
+
+	m, err := New(appName, defaultTestConfig(), ForcePath(userPath), WithSystemDirs())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.LoadOrCreate(); err != nil {
+		t.Fatalf("LoadOrCreate() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	writeTestFile(t, systemPath, "name = \"changed\"\ndebug = true\n")
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config(); got.Name != "changed" || !got.Debug {
+		t.Errorf("Config() = %+v, want values of edited system file", got)
+	}
+}
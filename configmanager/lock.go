@@ -0,0 +1,77 @@
+package configmanager
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrConflict is returned by Save when config file was changed on disk by
+// another process since it was loaded
+var ErrConflict = errors.New("config file was changed on disk since it was loaded")
+
+// lock places advisory cross-process lock on sidecar <path>.lock file and
+// returns function releasing it. Shared lock is skipped if config directory
+// does not exist or lock file can not be created (e.g. read-only mount).
+// Nothing is locked on filesystems set by WithFS.
+func (m *Manager[T]) lock(exclusive bool) (func(), error) {
+	if _, ok := m.fs.(osFS); !ok {
+		return func() {}, nil
+	}
+	lockPath := m.path + ".lock"
+	if exclusive {
+		if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to enshure config directory: %v", err)
+		}
+	}
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		if !exclusive {
+			return func() {}, nil
+		}
+		return nil, fmt.Errorf("failed to open lock file: %v", err)
+	}
+	if err := flock(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock config file: %v", err)
+	}
+	return func() {
+		funlock(f)
+		f.Close()
+	}, nil
+}
+
+// removeLockFile removes sidecar lock file of config path
+func (m *Manager[T]) removeLockFile() {
+	if _, ok := m.fs.(osFS); ok {
+		os.Remove(m.path + ".lock")
+	}
+}
+
+// checkConflict returns ErrConflict if config file differs from the one
+// seen by the last Load or Save
+func (m *Manager[T]) checkConflict() error {
+	if m.checksum == "" {
+		return nil
+	}
+	data, err := m.fs.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+	if checksum(data) != m.checksum {
+		return ErrConflict
+	}
+	return nil
+}
+
+// checksum returns hex encoded sha256 of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
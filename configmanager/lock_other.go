@@ -0,0 +1,15 @@
+//go:build !unix
+
+package configmanager
+
+import "os"
+
+// flock is a no-op on platforms without flock support
+func flock(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// funlock is a no-op on platforms without flock support
+func funlock(f *os.File) error {
+	return nil
+}
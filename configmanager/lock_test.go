@@ -0,0 +1,78 @@
+package configmanager
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestSaveConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	first, err := New("testapp", defaultTestConfig(), ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := first.LoadOrCreate(); err != nil {
+		t.Fatalf("LoadOrCreate() failed: %v", err)
+	}
+	second, err := New("testapp", defaultTestConfig(), ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := second.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if err := second.SetAndSave("name", "second"); err != nil {
+		t.Fatalf("SetAndSave() failed: %v", err)
+	}
+	if err := first.SetAndSave("name", "first"); !errors.Is(err, ErrConflict) {
+		t.Fatalf("SetAndSave() error = %v, want ErrConflict", err)
+	}
+	if err := first.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := first.Config().Name; got != "second" {
+		t.Errorf("Config().Name = %v, want second", got)
+	}
+	if err := first.SetAndSave("name", "first"); err != nil {
+		t.Fatalf("SetAndSave() after reload failed: %v", err)
+	}
+}
+
+func TestConcurrentSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m, err := New("testapp", defaultTestConfig(), ForcePath(path))
+			if err != nil {
+				errs <- err
+				return
+			}
+			errs <- m.Save()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Save() failed: %v", err)
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if name := entry.Name(); name != "config.toml" && name != "config.toml.lock" {
+			t.Errorf("unexpected file left in config directory: %v", name)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package configmanager
+
+import (
+	"os"
+	"syscall"
+)
+
+// flock places advisory lock on file
+func flock(f *os.File, exclusive bool) error {
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), how)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// funlock releases advisory lock on file
+func funlock(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
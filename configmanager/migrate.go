@@ -0,0 +1,173 @@
+package configmanager
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/Galdoba/appcontext/pathspec"
+)
+
+// VersionKey is the top level key holding schema version of versioned config files
+const VersionKey = "version"
+
+// MigrationFunc upgrades raw config document by one schema version in place
+type MigrationFunc func(doc map[string]any) error
+
+// WithSchemaVersion option enables versioning and sets current schema version of T.
+// Files without version key are treated as version 1.
+func WithSchemaVersion(version int) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.schemaVersion = version
+	}
+}
+
+// WithMigration option registers migration from version `from` to `from+1`
+func WithMigration(from int, migration MigrationFunc) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.migrations[from] = migration
+	}
+}
+
+// WithPathSpec option takes config path, format, versioning and backups from pathspec.Path
+func WithPathSpec(spec pathspec.Path) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.forceAlternativePath = spec.String()
+		if err := validateFormat(SerializationFormat(spec.Format)); err == nil {
+			mo.format = SerializationFormat(spec.Format)
+		}
+		if spec.IsVersioned && mo.schemaVersion == 0 {
+			mo.schemaVersion = 1
+		}
+		if spec.IsBackedUp && mo.backups == 0 {
+			mo.backups = int(pathspec.BackupStorageTemplate.MaxChildren)
+		}
+	}
+}
+
+// RegisterMigration registers migration from version `from` to `from+1`
+func (m *Manager[T]) RegisterMigration(from int, migration MigrationFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.schemaVersion == 0 {
+		return fmt.Errorf("versioning is not enabled")
+	}
+	if from < 1 || from >= m.schemaVersion {
+		return fmt.Errorf("migration from version %v is out of range [1; %v)", from, m.schemaVersion)
+	}
+	m.migrations[from] = migration
+	return nil
+}
+
+// SchemaVersion returns current schema version (0 if versioning is disabled)
+func (m *Manager[T]) SchemaVersion() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.schemaVersion
+}
+
+// validateVersioning checks that T can carry version key in format
+func validateVersioning[T any](schemaVersion int, format SerializationFormat) error {
+	if schemaVersion < 0 {
+		return fmt.Errorf("invalid schema version %v", schemaVersion)
+	}
+	if schemaVersion == 0 {
+		return nil
+	}
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return fmt.Errorf("versioned config must be a struct")
+	}
+	index, ok := findField(t, VersionKey, format)
+	if !ok {
+		return fmt.Errorf("versioned config must have '%v' field for %v format", VersionKey, format)
+	}
+	switch t.FieldByIndex(index).Type.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return fmt.Errorf("'%v' field must be integer", VersionKey)
+	}
+	return nil
+}
+
+// migrate upgrades raw config data to current schema version. Second value
+// reports whether data was changed. Migrated data is only decoded, the file
+// is written by the same render path as Save.
+func (m *Manager[T]) migrate(format SerializationFormat, data []byte) ([]byte, bool, error) {
+	if m.schemaVersion == 0 {
+		return data, false, nil
+	}
+	doc := make(map[string]any)
+	if err := decode(format, data, &doc); err != nil {
+		return nil, false, err
+	}
+	version := 1
+	if raw, ok := doc[VersionKey]; ok {
+		v, err := toInt(raw)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid %v: %v", VersionKey, err)
+		}
+		version = v
+	}
+	if version > m.schemaVersion {
+		return nil, false, fmt.Errorf("config version %v is newer than supported %v", version, m.schemaVersion)
+	}
+	if version == m.schemaVersion {
+		return data, false, nil
+	}
+	for ; version < m.schemaVersion; version++ {
+		migration, ok := m.migrations[version]
+		if !ok {
+			return nil, false, fmt.Errorf("no migration from version %v to %v", version, version+1)
+		}
+		if err := migration(doc); err != nil {
+			return nil, false, fmt.Errorf("migration from version %v to %v failed: %v", version, version+1, err)
+		}
+	}
+	doc[VersionKey] = m.schemaVersion
+	migrated, err := encode(format, doc)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode migrated config: %v", err)
+	}
+	return migrated, true, nil
+}
+
+// stampVersion sets version field of cfg to current schema version
+func (m *Manager[T]) stampVersion(cfg *T) {
+	if m.schemaVersion == 0 {
+		return
+	}
+	v := reflect.ValueOf(cfg).Elem()
+	if index, ok := findField(v.Type(), VersionKey, m.format); ok {
+		field := fieldByIndex(v, index, true)
+		switch field.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			field.SetInt(int64(m.schemaVersion))
+		default:
+			field.SetUint(uint64(m.schemaVersion))
+		}
+	}
+}
+
+// toInt converts decoded number to int
+func toInt(raw any) (int, error) {
+	switch v := raw.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case uint64:
+		if v > math.MaxInt {
+			return 0, fmt.Errorf("value %v is too large", v)
+		}
+		return int(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("value %v is not integer", v)
+		}
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("unexpected type %T", raw)
+	}
+}
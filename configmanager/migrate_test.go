@@ -0,0 +1,177 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+type versionedConfig struct {
+	Version    int    `toml:"version" yaml:"version" json:"version"`
+	ListenAddr string `toml:"listen_addr" yaml:"listen_addr" json:"listen_addr"`
+	LogLevel   string `toml:"log_level" yaml:"log_level" json:"log_level"`
+}
+
+func renameKey(from, to string) MigrationFunc {
+	return func(doc map[string]any) error {
+		if v, ok := doc[from]; ok {
+			doc[to] = v
+			delete(doc, from)
+		}
+		return nil
+	}
+}
+
+func TestLoad_Migrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  SerializationFormat
+		content string
+	}{
+		{name: "toml without version", format: TOML, content: "addr = \"127.0.0.1:80\"\nlevel = \"debug\"\n"},
+		{name: "yaml version 1", format: YAML, content: "version: 1\naddr: 127.0.0.1:80\nlevel: debug\n"},
+		{name: "json version 2", format: JSON, content: `{"version": 2, "listen_addr": "127.0.0.1:80", "level": "debug"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config."+string(tt.format))
+			writeTestFile(t, path, tt.content)
+			m, err := New("testapp", versionedConfig{},
+				ForcePath(path),
+				WithSerializationFormat(tt.format),
+				WithSchemaVersion(3),
+				WithMigration(1, renameKey("addr", "listen_addr")),
+				WithMigration(2, renameKey("level", "log_level")),
+			)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			want := versionedConfig{Version: 3, ListenAddr: "127.0.0.1:80", LogLevel: "debug"}
+			if got := m.Config(); got != want {
+				t.Errorf("Config() = %+v, want %+v", got, want)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read migrated file: %v", err)
+			}
+			if !strings.Contains(string(data), "log_level") {
+				t.Errorf("migrated file was not saved: %s", data)
+			}
+		})
+	}
+}
+
+func TestLoad_MigrationErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "missing migration step", content: "version = 2\n"},
+		{name: "newer version", content: "version = 4\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			writeTestFile(t, path, tt.content)
+			m, err := New("testapp", versionedConfig{},
+				ForcePath(path),
+				WithSchemaVersion(3),
+				WithMigration(1, renameKey("addr", "listen_addr")),
+			)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err == nil {
+				t.Error("Load() succeeded unexpectedly")
+			}
+		})
+	}
+}
+
+func TestNew_VersioningRequiresVersionField(t *testing.T) {
+	_, err := New("testapp", testConfig{}, ForcePath(filepath.Join(t.TempDir(), "config.toml")), WithSchemaVersion(2))
+	if err == nil {
+		t.Error("New() succeeded unexpectedly")
+	}
+}
+
+func TestLoad_MigrationReadOnly(t *testing.T) {
+	diskPath := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, diskPath, "addr = \"127.0.0.1:80\"\n")
+	tests := []struct {
+		name string
+		fsys FS
+		path string
+	}{
+		{name: "embed", fsys: FromFS(fstest.MapFS{"config.toml": {Data: []byte("addr = \"127.0.0.1:80\"\n")}}), path: "/config.toml"},
+		{name: "read-only mount", fsys: ReadOnly(OSFS()), path: diskPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New("testapp", versionedConfig{},
+				WithFS(tt.fsys),
+				ForcePath(tt.path),
+				WithBackups(3),
+				WithSchemaVersion(2),
+				WithMigration(1, renameKey("addr", "listen_addr")),
+			)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			want := versionedConfig{Version: 2, ListenAddr: "127.0.0.1:80"}
+			if got := m.Config(); got != want {
+				t.Errorf("Config() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoad_MigrationKeepsFormatting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, path, "# log settings\nlog_level = \"debug\" # verbose\n# where to listen\nlisten_addr = \"127.0.0.1:80\"\n")
+	m, err := New("testapp", versionedConfig{},
+		ForcePath(path),
+		WithPreserveFormatting(),
+		WithSchemaVersion(2),
+		WithMigration(1, func(doc map[string]any) error {
+			doc["log_level"] = "info"
+			return nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read migrated file: %v", err)
+	}
+	for _, want := range []string{"# log settings\nlog_level = 'info' # verbose\n# where to listen\nlisten_addr = \"127.0.0.1:80\"\n", "version = 2"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("migrated file does not contain %q:\n%s", want, data)
+		}
+	}
+}
+
+func TestNew_VersionFieldOfFormat(t *testing.T) {
+	type yamlConfig struct {
+		Revision int `yaml:"version"`
+	}
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if _, err := New("testapp", yamlConfig{}, ForcePath(path), WithSerializationFormat(YAML), WithSchemaVersion(2)); err != nil {
+		t.Errorf("New() of YAML config with yaml version tag failed: %v", err)
+	}
+	if _, err := New("testapp", yamlConfig{}, ForcePath(filepath.Join(t.TempDir(), "config.toml")), WithSchemaVersion(2)); err == nil {
+		t.Error("New() of TOML config without version field succeeded")
+	}
+}
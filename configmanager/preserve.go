@@ -0,0 +1,357 @@
+package configmanager
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// WithPreserveFormatting option makes Save patch only changed values into the
+// existing TOML or YAML file so user comments, formatting and key order survive.
+// If the file can not be patched safely it is rewritten as usual.
+func WithPreserveFormatting() ManagerOption {
+	return func(mo *managerOptions) {
+		mo.preserveFormatting = true
+	}
+}
+
+// docChange is a single difference between two config documents
+type docChange struct {
+	keys    []string
+	old     any
+	value   any
+	existed bool
+	deleted bool
+}
+
+// diffDocs returns changes required to turn old document into new one
+func diffDocs(old, new map[string]any, prefix []string) []docChange {
+	changes := []docChange{}
+	for key, newValue := range new {
+		keys := append(append([]string{}, prefix...), key)
+		oldValue, ok := old[key]
+		if !ok {
+			changes = append(changes, docChange{keys: keys, value: newValue})
+			continue
+		}
+		oldMap, oldIsMap := oldValue.(map[string]any)
+		newMap, newIsMap := newValue.(map[string]any)
+		if oldIsMap && newIsMap {
+			changes = append(changes, diffDocs(oldMap, newMap, keys)...)
+			continue
+		}
+		if !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, docChange{keys: keys, old: oldValue, value: newValue, existed: true})
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			keys := append(append([]string{}, prefix...), key)
+			changes = append(changes, docChange{keys: keys, old: old[key], existed: true, deleted: true})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return strings.Join(changes[i].keys, "\x00") < strings.Join(changes[j].keys, "\x00")
+	})
+	return changes
+}
+
+// preserve patches existing config file with changes needed to match data.
+// Second value is false if existing file can not be patched.
+func (m *Manager[T]) preserve(data []byte) ([]byte, bool) {
+	original, err := m.fs.ReadFile(m.path)
+	if err != nil {
+		return nil, false
+	}
+	oldDoc := make(map[string]any)
+	if err := decode(m.format, original, &oldDoc); err != nil {
+		return nil, false
+	}
+	newDoc := make(map[string]any)
+	if err := decode(m.format, data, &newDoc); err != nil {
+		return nil, false
+	}
+	changes := diffDocs(oldDoc, newDoc, nil)
+	if len(changes) == 0 {
+		return original, true
+	}
+	var patched []byte
+	switch m.format {
+	case TOML:
+		patched, err = patchTOML(original, changes)
+	case YAML:
+		patched, err = patchYAML(original, changes)
+	default:
+		return nil, false
+	}
+	if err != nil {
+		return nil, false
+	}
+	patchedDoc := make(map[string]any)
+	if err := decode(m.format, patched, &patchedDoc); err != nil || !reflect.DeepEqual(patchedDoc, newDoc) {
+		return nil, false
+	}
+	return patched, true
+}
+
+// tomlDocLine describes a line of TOML document
+type tomlDocLine struct {
+	text  string
+	keys  []string
+	value string
+	table bool
+}
+
+// patchTOML applies changes to TOML document line by line
+func patchTOML(data []byte, changes []docChange) ([]byte, error) {
+	lines := []tomlDocLine{}
+	table := []string{}
+	tableEnds := map[string]int{"": -1}
+	firstTable := -1
+	for i, text := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		line := tomlDocLine{text: text}
+		if match := tomlTableLine.FindStringSubmatch(text); match != nil {
+			if strings.HasPrefix(strings.TrimSpace(text), "[[") {
+				table = nil
+			} else {
+				table = []string{}
+				for _, key := range strings.Split(match[2], ".") {
+					table = append(table, unquoteKey(strings.TrimSpace(key)))
+				}
+				line.keys = table
+				line.table = true
+				tableEnds[strings.Join(table, ".")] = i
+			}
+			if firstTable < 0 {
+				firstTable = i
+			}
+		} else if match := tomlKeyLine.FindStringSubmatch(text); match != nil && table != nil {
+			line.keys = append(append([]string{}, table...), unquoteKey(match[2]))
+			line.value = text[len(match[0]):]
+			tableEnds[strings.Join(table, ".")] = i
+		}
+		lines = append(lines, line)
+	}
+	if firstTable < 0 {
+		tableEnds[""] = len(lines) - 1
+	}
+
+	findLine := func(keys []string) int {
+		for i, line := range lines {
+			if !line.table && line.keys != nil && strings.Join(line.keys, ".") == strings.Join(keys, ".") {
+				return i
+			}
+		}
+		return -1
+	}
+	inserts := make(map[int][]string)
+	deleted := make(map[int]bool)
+	appendix := []string{}
+	for _, change := range changes {
+		key := change.keys[len(change.keys)-1]
+		parent := strings.Join(change.keys[:len(change.keys)-1], ".")
+		switch {
+		case change.deleted:
+			i := findLine(change.keys)
+			if i < 0 || !isSingleLineTOMLValue(lines[i].value) {
+				return nil, fmt.Errorf("can not delete %v", strings.Join(change.keys, "."))
+			}
+			deleted[i] = true
+		case change.existed:
+			i := findLine(change.keys)
+			if i < 0 || !isSingleLineTOMLValue(lines[i].value) {
+				return nil, fmt.Errorf("can not replace %v", strings.Join(change.keys, "."))
+			}
+			value, err := encodeTOMLValue(change.value)
+			if err != nil {
+				return nil, err
+			}
+			text := lines[i].text
+			prefix := text[:len(text)-len(lines[i].value)]
+			lines[i].text = prefix + " " + value + tomlTrailingComment(lines[i].value)
+		default:
+			end, ok := tableEnds[parent]
+			_, isMap := change.value.(map[string]any)
+			if !ok || isMap {
+				nested := change.value
+				for i := len(change.keys) - 1; i >= 0; i-- {
+					nested = map[string]any{change.keys[i]: nested}
+				}
+				section, err := toml.Marshal(nested)
+				if err != nil {
+					return nil, err
+				}
+				appendix = append(appendix, "", strings.TrimSuffix(string(section), "\n"))
+				continue
+			}
+			value, err := encodeTOMLValue(change.value)
+			if err != nil {
+				return nil, err
+			}
+			keyText, err := encodeTOMLValue(key)
+			if err != nil {
+				return nil, err
+			}
+			if isBareKey(key) {
+				keyText = key
+			}
+			if parent == "" && firstTable >= 0 && end < 0 {
+				end = firstTable - 1
+			}
+			inserts[end] = append(inserts[end], keyText+" = "+value)
+		}
+	}
+
+	var out bytes.Buffer
+	for _, text := range inserts[-1] {
+		out.WriteString(text + "\n")
+	}
+	for i, line := range lines {
+		if !deleted[i] {
+			out.WriteString(line.text + "\n")
+		}
+		for _, text := range inserts[i] {
+			out.WriteString(text + "\n")
+		}
+	}
+	for _, text := range appendix {
+		out.WriteString(text + "\n")
+	}
+	return out.Bytes(), nil
+}
+
+// encodeTOMLValue encodes single value as inline TOML
+func encodeTOMLValue(value any) (string, error) {
+	data, err := toml.Marshal(map[string]any{"v": value})
+	if err != nil {
+		return "", err
+	}
+	text := strings.TrimSuffix(string(data), "\n")
+	if !strings.HasPrefix(text, "v = ") || strings.Contains(text, "\n") {
+		return "", fmt.Errorf("value can not be inlined")
+	}
+	return strings.TrimPrefix(text, "v = "), nil
+}
+
+// isBareKey reports whether key can be written without quotes
+func isBareKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// splitTOMLValue returns bracket depth at the end of TOML value and index of
+// its trailing comment (-1 if there is no comment)
+func splitTOMLValue(value string) (int, int) {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == '#':
+			return depth, i
+		}
+	}
+	return depth, -1
+}
+
+// isSingleLineTOMLValue reports whether value is fully written on its line
+func isSingleLineTOMLValue(value string) bool {
+	trimmed := strings.TrimSpace(value)
+	if strings.HasPrefix(trimmed, `"""`) || strings.HasPrefix(trimmed, `'''`) {
+		return false
+	}
+	depth, _ := splitTOMLValue(value)
+	return depth == 0
+}
+
+// tomlTrailingComment returns comment following the value with its leading spaces
+func tomlTrailingComment(value string) string {
+	_, start := splitTOMLValue(value)
+	if start < 0 {
+		return ""
+	}
+	spaces := len(value[:start]) - len(strings.TrimRight(value[:start], " \t"))
+	if spaces == 0 {
+		spaces = 1
+	}
+	return strings.Repeat(" ", spaces) + value[start:]
+}
+
+// patchYAML applies changes to YAML document through its AST
+func patchYAML(data []byte, changes []docChange) ([]byte, error) {
+	file, err := parser.ParseBytes(data, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Docs) != 1 {
+		return nil, fmt.Errorf("expected single yaml document")
+	}
+	for _, change := range changes {
+		parent := (&yaml.PathBuilder{}).Root()
+		for _, key := range change.keys[:len(change.keys)-1] {
+			parent = parent.Child(key)
+		}
+		key := change.keys[len(change.keys)-1]
+		switch {
+		case change.deleted:
+			node, err := parent.Build().FilterFile(file)
+			if err != nil {
+				return nil, err
+			}
+			mapping, ok := node.(*ast.MappingNode)
+			if !ok {
+				return nil, fmt.Errorf("can not delete %v", strings.Join(change.keys, "."))
+			}
+			values := []*ast.MappingValueNode{}
+			for _, value := range mapping.Values {
+				if value.Key.GetToken().Value != key {
+					values = append(values, value)
+				}
+			}
+			mapping.Values = values
+		case change.existed:
+			value, err := yaml.Marshal(change.value)
+			if err != nil {
+				return nil, err
+			}
+			if err := parent.Child(key).Build().ReplaceWithReader(file, bytes.NewReader(value)); err != nil {
+				return nil, err
+			}
+		default:
+			value, err := yaml.Marshal(map[string]any{key: change.value})
+			if err != nil {
+				return nil, err
+			}
+			if err := parent.Build().MergeFromReader(file, bytes.NewReader(value)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return []byte(strings.TrimSuffix(file.String(), "\n") + "\n"), nil
+}
@@ -0,0 +1,99 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type preservedServer struct {
+	Port int    `toml:"port" yaml:"port"`
+	Host string `toml:"host" yaml:"host"`
+}
+
+type preservedConfig struct {
+	Name   string            `toml:"name" yaml:"name"`
+	Debug  bool              `toml:"debug" yaml:"debug"`
+	Tags   []string          `toml:"tags" yaml:"tags"`
+	Server preservedServer   `toml:"server" yaml:"server"`
+	Labels map[string]string `toml:"labels" yaml:"labels"`
+}
+
+func TestSave_PreserveFormatting(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   SerializationFormat
+		original string
+		system   string
+		set      map[string]any
+		want     string
+	}{
+		{
+			name:   "toml changed values",
+			format: TOML,
+			original: "# my config\nname = \"old\" # keep me\ndebug = false\ntags = [\"a\"]\n\n" +
+				"[server]\n# the port\nport = 1\nhost = \"h\"\n",
+			set: map[string]any{"name": "new", "server.port": 2},
+			want: "# my config\nname = 'new' # keep me\ndebug = false\ntags = [\"a\"]\n\n" +
+				"[server]\n# the port\nport = 2\nhost = \"h\"\n",
+		},
+		{
+			name:     "toml added keys",
+			format:   TOML,
+			original: "# my config\nname = \"old\"\n\n[server]\nport = 1\n",
+			set:      map[string]any{"debug": true, "server.host": "h", "labels": map[string]string{"env": "prod"}},
+			want:     "# my config\nname = \"old\"\ndebug = true\ntags = []\n\n[server]\nport = 1\nhost = 'h'\n\n[labels]\nenv = 'prod'\n",
+		},
+		{
+			name:     "toml with system file",
+			format:   TOML,
+			original: "# my config\nname = \"old\"\n",
+			system:   "debug = true\n[server]\nport = 5\nhost = \"sys\"\n",
+			set:      map[string]any{"server.host": "h"},
+			want:     "# my config\nname = \"old\"\n\n[server]\nhost = 'h'\n",
+		},
+		{
+			name:   "yaml changed values",
+			format: YAML,
+			original: "# my config\nname: old\ndebug: false\ntags:\n  - a\nserver:\n  # the port\n  port: 1\n" +
+				"  host: h\nlabels: {}\n",
+			set: map[string]any{"server.port": 2, "tags": []string{"a", "b"}},
+			want: "# my config\nname: old\ndebug: false\ntags:\n  - a\n  - b\nserver:\n  # the port\n  port: 2\n" +
+				"  host: h\nlabels: {}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config."+string(tt.format))
+			writeTestFile(t, path, tt.original)
+			options := []ManagerOption{ForcePath(path), WithSerializationFormat(tt.format), WithPreserveFormatting()}
+			if tt.system != "" {
+				systemPath := filepath.Join(filepath.Dir(path), "system."+string(tt.format))
+				writeTestFile(t, systemPath, tt.system)
+				options = append(options, WithSystemFile(systemPath))
+			}
+			m, err := New("testapp", preservedConfig{}, options...)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			for key, value := range tt.set {
+				if err := m.Set(key, value); err != nil {
+					t.Fatalf("Set(%v) failed: %v", key, err)
+				}
+			}
+			if err := m.Save(); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("failed to read config: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("Save() wrote:\n%s\nwant:\n%s", data, tt.want)
+			}
+		})
+	}
+}
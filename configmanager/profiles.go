@@ -0,0 +1,167 @@
+package configmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// SliceMergeMode defines how slices of profile file are merged into base config
+type SliceMergeMode int
+
+const (
+	// SliceReplace replaces base slice with profile slice
+	SliceReplace SliceMergeMode = iota
+	// SliceAppend appends profile slice to base slice
+	SliceAppend
+)
+
+// WithProfile option selects named profile. Profile file config.<profile>.<ext>
+// next to Path() is deep merged over the base config file. Save writes the base
+// file only, profile values are never copied into it. Unlike other optional
+// layers, profile file must exist: Load fails if it is missing, so that
+// misspelled profile never falls back to the base config silently.
+func WithProfile(profile string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.profile = profile
+	}
+}
+
+// WithProfileEnv option selects the profile from environment variable name
+// when WithProfile is not used. Empty name means <APPNAME>_PROFILE. Without
+// this option environment never selects a profile.
+func WithProfileEnv(name string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.profileEnv = name
+		mo.profileFromEnv = true
+	}
+}
+
+// WithSliceMerge option sets how slices are merged from profile file
+func WithSliceMerge(mode SliceMergeMode) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.sliceMerge = mode
+	}
+}
+
+// Profile returns selected profile name (empty if no profile is used)
+func (m *Manager[T]) Profile() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.profile
+}
+
+// selectProfile returns profile from option or environment variable if enabled
+func selectProfile(appName string, mo managerOptions) (string, error) {
+	profile := mo.profile
+	if profile == "" && mo.profileFromEnv {
+		envName := mo.profileEnv
+		if envName == "" {
+			envName = defaultProfileEnv(appName)
+		}
+		profile = os.Getenv(envName)
+	}
+	if strings.ContainsAny(profile, `/\`) || profile == "." || profile == ".." {
+		return "", fmt.Errorf("invalid profile name '%v'", profile)
+	}
+	return profile, nil
+}
+
+// defaultProfileEnv derives profile environment variable name from application name
+func defaultProfileEnv(appName string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, appName)
+	return strings.ToUpper(name) + "_PROFILE"
+}
+
+// profilePath returns path of profile file for base config path
+func profilePath(base, profile string) string {
+	ext := filepath.Ext(base)
+	return strings.TrimSuffix(base, ext) + "." + profile + ext
+}
+
+// mergeFile deep merges raw profile or fragment document into cfg
+func (m *Manager[T]) mergeFile(format SerializationFormat, data []byte, cfg *T) error {
+	doc := make(map[string]any)
+	if err := decode(format, data, &doc); err != nil {
+		return err
+	}
+	return mergeDoc(reflect.ValueOf(cfg).Elem(), doc, format, m.sliceMerge)
+}
+
+// mergeDoc deep merges decoded document value into v. Structs and maps are
+// merged key by key, slices are replaced or appended according to mode.
+func mergeDoc(v reflect.Value, doc any, format SerializationFormat, mode SliceMergeMode) error {
+	docMap, isMap := doc.(map[string]any)
+	switch {
+	case v.Kind() == reflect.Pointer && doc != nil:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return mergeDoc(v.Elem(), doc, format, mode)
+	case v.Kind() == reflect.Struct && isMap && !(v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType)):
+		for key, value := range docMap {
+			index, ok := findField(v.Type(), key, format)
+			if !ok {
+				continue
+			}
+			if err := mergeDoc(fieldByIndex(v, index, true), value, format, mode); err != nil {
+				return fmt.Errorf("%v: %v", key, err)
+			}
+		}
+		return nil
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String && isMap:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for key, value := range docMap {
+			mapKey := reflect.ValueOf(key).Convert(v.Type().Key())
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if current := v.MapIndex(mapKey); current.IsValid() {
+				elem.Set(cloneValue(current))
+			}
+			if err := mergeDoc(elem, value, format, mode); err != nil {
+				return fmt.Errorf("%v: %v", key, err)
+			}
+			v.SetMapIndex(mapKey, elem)
+		}
+		return nil
+	case v.Kind() == reflect.Slice && mode == SliceAppend:
+		items := reflect.New(v.Type()).Elem()
+		if err := decodeValue(items, doc, format); err != nil {
+			return err
+		}
+		v.Set(reflect.AppendSlice(v, items))
+		return nil
+	default:
+		fresh := reflect.New(v.Type()).Elem()
+		if err := decodeValue(fresh, doc, format); err != nil {
+			return err
+		}
+		v.Set(fresh)
+		return nil
+	}
+}
+
+// decodeValue decodes document value into v using serialization format of the file,
+// so that tags and custom unmarshalers of the field type are honoured
+func decodeValue(v reflect.Value, doc any, format SerializationFormat) error {
+	tag := reflect.StructTag(fmt.Sprintf(`%v:"v"`, tagName(format)))
+	wrapperType := reflect.StructOf([]reflect.StructField{{Name: "V", Type: v.Type(), Tag: tag}})
+	data, err := encode(format, map[string]any{"v": doc})
+	if err != nil {
+		return err
+	}
+	wrapper := reflect.New(wrapperType)
+	if err := decode(format, data, wrapper.Interface()); err != nil {
+		return err
+	}
+	v.Set(wrapper.Elem().Field(0))
+	return nil
+}
@@ -0,0 +1,150 @@
+package configmanager
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type profileConfig struct {
+	Tags   []string          `toml:"tags" yaml:"tags"`
+	Labels map[string]string `toml:"labels" yaml:"labels"`
+	Server testServer        `toml:"server" yaml:"server"`
+}
+
+func TestLoad_Profile(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   SerializationFormat
+		base     string
+		profile  string
+		mode     SliceMergeMode
+		wantTags []string
+	}{
+		{
+			name:     "toml replace",
+			format:   TOML,
+			base:     "tags = [\"a\"]\n[labels]\nteam = \"core\"\n[server]\nhost = \"base\"\nport = 1\n",
+			profile:  "tags = [\"b\"]\n[labels]\nenv = \"prod\"\n[server]\nport = 443\n",
+			mode:     SliceReplace,
+			wantTags: []string{"b"},
+		},
+		{
+			name:     "yaml append",
+			format:   YAML,
+			base:     "tags: [a]\nlabels:\n  team: core\nserver:\n  host: base\n  port: 1\n",
+			profile:  "tags: [b]\nlabels:\n  env: prod\nserver:\n  port: 443\n",
+			mode:     SliceAppend,
+			wantTags: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config."+string(tt.format))
+			writeTestFile(t, path, tt.base)
+			writeTestFile(t, filepath.Join(dir, "config.prod."+string(tt.format)), tt.profile)
+			t.Setenv("TESTAPP_PROFILE", "prod")
+
+			m, err := New("testapp", profileConfig{}, ForcePath(path), WithSerializationFormat(tt.format),
+				WithSliceMerge(tt.mode), WithProfileEnv(""))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if m.Profile() != "prod" {
+				t.Errorf("Profile() = %v, want prod", m.Profile())
+			}
+			if err := m.Load(); err != nil {
+				t.Fatalf("Load() failed: %v", err)
+			}
+			want := profileConfig{
+				Tags:   tt.wantTags,
+				Labels: map[string]string{"team": "core", "env": "prod"},
+				Server: testServer{Host: "base", Port: 443},
+			}
+			if got := m.Config(); !reflect.DeepEqual(got, want) {
+				t.Errorf("Config() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoad_MissingProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, path, "tags = []\n")
+	m, err := New("testapp", profileConfig{}, ForcePath(path), WithProfile("staging"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err == nil || !strings.Contains(err.Error(), "config.staging.toml") {
+		t.Errorf("Load() error = %v, want missing profile file error", err)
+	}
+}
+
+func TestNew_ProfileEnv(t *testing.T) {
+	t.Setenv("TESTAPP_PROFILE", "prod")
+	t.Setenv("CUSTOM_PROFILE", "dev")
+	tests := []struct {
+		name    string
+		options []ManagerOption
+		want    string
+	}{
+		{name: "env is ignored by default", want: ""},
+		{name: "default variable", options: []ManagerOption{WithProfileEnv("")}, want: "prod"},
+		{name: "custom variable", options: []ManagerOption{WithProfileEnv("CUSTOM_PROFILE")}, want: "dev"},
+		{name: "option wins", options: []ManagerOption{WithProfileEnv(""), WithProfile("test")}, want: "test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := append([]ManagerOption{ForcePath(filepath.Join(t.TempDir(), "config.toml"))}, tt.options...)
+			m, err := New("testapp", profileConfig{}, options...)
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if got := m.Profile(); got != tt.want {
+				t.Errorf("Profile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSave_Profile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.toml")
+	profilePath := filepath.Join(dir, "config.prod.toml")
+	writeTestFile(t, path, "[server]\nhost = \"base\"\nport = 1\n")
+	writeTestFile(t, profilePath, "tags = [\"p\"]\n[labels]\nenv = \"prod\"\n[server]\nport = 9\n")
+
+	m, err := New("testapp", profileConfig{}, ForcePath(path), WithProfile("prod"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if err := m.Set("server.host", "changed"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	base, err := New("testapp", profileConfig{}, ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := base.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	got := base.Config()
+	if got.Server.Port != 1 || got.Server.Host != "changed" || len(got.Tags) != 0 || len(got.Labels) != 0 {
+		t.Errorf("base config after profile Save() = %+v, want no profile values", got)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := m.Config(); got.Server.Port != 9 || got.Server.Host != "changed" {
+		t.Errorf("Config() with profile = %+v", got)
+	}
+}
@@ -0,0 +1,348 @@
+package configmanager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Section is a manager registered in Registry. *Manager[T] implements it.
+type Section interface {
+	Path() string
+	acquire() (func(), error)
+	stageLoad() (any, stagedLoad, error)
+	stageSave() (any, error)
+	writeStaged() (func() error, error)
+	captureFile() (fileSnapshot, error)
+	rollback(snap fileSnapshot) error
+}
+
+// RegistryValidator checks configurations of several sections together.
+// Configs maps section names to config values of type T of their managers.
+type RegistryValidator func(configs map[string]any) error
+
+// Registry composes managers of one application, each bound to its own file
+// (see WithFileName), and loads, validates and saves them together.
+// Either every section is loaded or saved, or none of them is changed.
+type Registry struct {
+	mu         sync.Mutex
+	names      []string
+	sections   map[string]Section
+	validators []RegistryValidator
+}
+
+// stagedLoad is resolved configuration of a section waiting to be applied
+type stagedLoad struct {
+	// save writes migrated config file and returns function keeping its
+	// backup, or nil if the file was not written
+	save func() (func() error, error)
+	// use makes configuration current
+	use func()
+}
+
+// fileSnapshot is config file content taken before registry save
+type fileSnapshot struct {
+	data     []byte
+	existed  bool
+	checksum string
+}
+
+// NewRegistry creates empty Registry
+func NewRegistry() *Registry {
+	return &Registry{sections: make(map[string]Section)}
+}
+
+// Add registers manager under section name. Sections must use distinct files.
+func (r *Registry) Add(name string, section Section) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" || section == nil {
+		return fmt.Errorf("section name and manager must be set")
+	}
+	if _, ok := r.sections[name]; ok {
+		return fmt.Errorf("section %v is already registered", name)
+	}
+	for _, other := range r.names {
+		if filepath.Clean(r.sections[other].Path()) == filepath.Clean(section.Path()) {
+			return fmt.Errorf("sections %v and %v use the same file %v", other, name, section.Path())
+		}
+	}
+	r.names = append(r.names, name)
+	r.sections[name] = section
+	return nil
+}
+
+// AddValidator adds cross section validator run by Load, Save and Validate
+func (r *Registry) AddValidator(validator RegistryValidator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.validators = append(r.validators, validator)
+}
+
+// Names returns section names in order of registration
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.names...)
+}
+
+// SectionOf returns manager registered under section name
+func SectionOf[T any](r *Registry, name string) (*Manager[T], error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	section, ok := r.sections[name]
+	if !ok {
+		return nil, fmt.Errorf("section %v is not registered", name)
+	}
+	m, ok := section.(*Manager[T])
+	if !ok {
+		return nil, fmt.Errorf("section %v is %T, not *Manager[%T]", name, section, *new(T))
+	}
+	return m, nil
+}
+
+// Load resolves and validates every section, then applies them. Current
+// configuration of all sections is kept intact if any section fails.
+func (r *Registry) Load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	release, err := r.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	configs := make(map[string]any, len(r.names))
+	stages := make([]stagedLoad, 0, len(r.names))
+	for _, name := range r.names {
+		cfg, stage, err := r.sections[name].stageLoad()
+		if err != nil {
+			return fmt.Errorf("section %v: %w", name, err)
+		}
+		configs[name] = cfg
+		stages = append(stages, stage)
+	}
+	if err := r.validate(configs); err != nil {
+		return err
+	}
+	snapshots, err := r.capture()
+	if err != nil {
+		return err
+	}
+	commits := make([]func() error, 0, len(r.names))
+	for i, stage := range stages {
+		commit, err := stage.save()
+		if err != nil {
+			return r.rollback(i, err, snapshots, commits)
+		}
+		commits = append(commits, commit)
+	}
+	for _, stage := range stages {
+		stage.use()
+	}
+	return r.commit(commits)
+}
+
+// Save validates every section and writes their files. If any file can not be
+// written, files already written by this call are restored. Backups and audit
+// log entries are only kept after every file is written.
+func (r *Registry) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	release, err := r.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	configs := make(map[string]any, len(r.names))
+	for _, name := range r.names {
+		cfg, err := r.sections[name].stageSave()
+		if err != nil {
+			return fmt.Errorf("section %v: %w", name, err)
+		}
+		configs[name] = cfg
+	}
+	if err := r.validate(configs); err != nil {
+		return err
+	}
+	snapshots, err := r.capture()
+	if err != nil {
+		return err
+	}
+	commits := make([]func() error, 0, len(r.names))
+	for i, name := range r.names {
+		commit, err := r.sections[name].writeStaged()
+		if err != nil {
+			return r.rollback(i, err, snapshots, commits)
+		}
+		commits = append(commits, commit)
+	}
+	return r.commit(commits)
+}
+
+// capture returns snapshots of config files of every section
+func (r *Registry) capture() ([]fileSnapshot, error) {
+	snapshots := make([]fileSnapshot, 0, len(r.names))
+	for _, name := range r.names {
+		snap, err := r.sections[name].captureFile()
+		if err != nil {
+			return nil, fmt.Errorf("section %v: %w", name, err)
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, nil
+}
+
+// rollback restores files written before section failed with err. Files of
+// sections with nil commit were not written. Every file is restored even if
+// some of them fail.
+func (r *Registry) rollback(failed int, err error, snapshots []fileSnapshot, commits []func() error) error {
+	errs := []error{fmt.Errorf("section %v: %w", r.names[failed], err)}
+	for i := len(commits) - 1; i >= 0; i-- {
+		if commits[i] == nil {
+			continue
+		}
+		if rbErr := r.sections[r.names[i]].rollback(snapshots[i]); rbErr != nil {
+			errs = append(errs, fmt.Errorf("rollback of section %v failed: %w", r.names[i], rbErr))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// commit keeps backups and audit entries of written sections
+func (r *Registry) commit(commits []func() error) error {
+	errs := []error{}
+	for i, commit := range commits {
+		if commit == nil {
+			continue
+		}
+		if err := commit(); err != nil {
+			errs = append(errs, fmt.Errorf("section %v: %w", r.names[i], err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks current configuration of every section and runs cross
+// section validators without loading or saving files
+func (r *Registry) Validate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	release, err := r.acquire()
+	if err != nil {
+		return err
+	}
+	defer release()
+	configs := make(map[string]any, len(r.names))
+	for _, name := range r.names {
+		cfg, err := r.sections[name].stageSave()
+		if err != nil {
+			return fmt.Errorf("section %v: %w", name, err)
+		}
+		configs[name] = cfg
+	}
+	return r.validate(configs)
+}
+
+// acquire locks every section in order of registration
+func (r *Registry) acquire() (func(), error) {
+	releases := make([]func(), 0, len(r.names))
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, name := range r.names {
+		rel, err := r.sections[name].acquire()
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("section %v: %w", name, err)
+		}
+		releases = append(releases, rel)
+	}
+	return release, nil
+}
+
+// validate runs cross section validators
+func (r *Registry) validate(configs map[string]any) error {
+	for _, validator := range r.validators {
+		if err := validator(configs); err != nil {
+			return fmt.Errorf("cross section validation failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// acquire takes in-process and exclusive file locks of manager
+func (m *Manager[T]) acquire() (func(), error) {
+	m.mu.Lock()
+	unlock, err := m.lock(true)
+	if err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	return func() {
+		unlock()
+		m.mu.Unlock()
+	}, nil
+}
+
+// stageLoad resolves configuration and returns it with functions applying it
+func (m *Manager[T]) stageLoad() (any, stagedLoad, error) {
+	res, err := m.prepare()
+	if err != nil {
+		return nil, stagedLoad{}, err
+	}
+	return *res.config, stagedLoad{
+		save: func() (func() error, error) { return m.saveMigrated(res) },
+		use:  func() { m.use(res) },
+	}, nil
+}
+
+// stageSave checks that current configuration can be saved and returns it
+func (m *Manager[T]) stageSave() (any, error) {
+	if err := m.validate(m.config); err != nil {
+		return nil, fmt.Errorf("validation failed before save: %w", err)
+	}
+	if err := m.checkConflict(); err != nil {
+		return nil, err
+	}
+	return *m.config, nil
+}
+
+// writeStaged saves current configuration and returns function keeping its
+// backup and audit entry
+func (m *Manager[T]) writeStaged() (func() error, error) {
+	m.stampVersion(m.base)
+	data, err := m.prepareWrite(m.base, m.owned)
+	if err != nil {
+		return nil, err
+	}
+	return m.stageData(data)
+}
+
+// captureFile returns current content of config file
+func (m *Manager[T]) captureFile() (fileSnapshot, error) {
+	data, err := m.fs.ReadFile(m.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fileSnapshot{checksum: m.checksum}, nil
+		}
+		return fileSnapshot{}, fmt.Errorf("failed to read config file: %v", err)
+	}
+	return fileSnapshot{data: data, existed: true, checksum: m.checksum}, nil
+}
+
+// rollback restores config file from snapshot
+func (m *Manager[T]) rollback(snap fileSnapshot) error {
+	if !snap.existed {
+		if err := m.fs.Remove(m.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else if err := m.fs.WriteFile(m.path, snap.data, m.filePerm()); err != nil {
+		return err
+	}
+	m.checksum = snap.checksum
+	return nil
+}
@@ -0,0 +1,271 @@
+package configmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type uiConfig struct {
+	Theme string `toml:"theme" yaml:"theme"`
+	Port  int    `toml:"port" yaml:"port"`
+}
+
+type networkConfig struct {
+	Port int `toml:"port" yaml:"port" validate:"min=1"`
+}
+
+func newTestRegistry(t *testing.T, networkPath string, uiOptions ...ManagerOption) (*Registry, *Manager[uiConfig], *Manager[networkConfig]) {
+	t.Helper()
+	ui, err := New("testapp", uiConfig{Theme: "dark", Port: 8080}, append([]ManagerOption{WithFileName("ui")}, uiOptions...)...)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	options := []ManagerOption{WithFileName("network"), WithSerializationFormat(YAML)}
+	if networkPath != "" {
+		options = append(options, ForcePath(networkPath))
+	}
+	network, err := New("testapp", networkConfig{Port: 9090}, options...)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	r := NewRegistry()
+	if err := r.Add("ui", ui); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := r.Add("network", network); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	r.AddValidator(func(configs map[string]any) error {
+		if configs["ui"].(uiConfig).Port == configs["network"].(networkConfig).Port {
+			return fmt.Errorf("ui and network use the same port")
+		}
+		return nil
+	})
+	return r, ui, network
+}
+
+func TestRegistry(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configHome := filepath.Join(home, ".config")
+	r, ui, network := newTestRegistry(t, "")
+
+	wantPaths := map[string]string{
+		ui.Path():      filepath.Join(configHome, "testapp", "ui.toml"),
+		network.Path(): filepath.Join(configHome, "testapp", "network.yaml"),
+	}
+	for got, want := range wantPaths {
+		if got != want {
+			t.Errorf("Path() = %v, want %v", got, want)
+		}
+	}
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	if err := r.Add("other", ui); err == nil {
+		t.Error("Add() of manager with the same file succeeded")
+	}
+	if _, err := SectionOf[networkConfig](r, "ui"); err == nil {
+		t.Error("SectionOf() with wrong type succeeded")
+	}
+	if got, err := SectionOf[uiConfig](r, "ui"); err != nil || got != ui {
+		t.Errorf("SectionOf() = %v, %v", got, err)
+	}
+
+	if err := ui.Set("port", "9090"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := r.Validate(); err == nil {
+		t.Error("Validate() accepted conflicting sections")
+	}
+	if err := r.Save(); err == nil {
+		t.Error("Save() accepted conflicting sections")
+	}
+
+	writeTestFile(t, ui.Path(), "theme = \"light\"\nport = 7070\n")
+	writeTestFile(t, network.Path(), "port: 0\n")
+	if err := r.Load(); err == nil {
+		t.Fatal("Load() with invalid section succeeded")
+	}
+	if got := ui.Config(); got.Theme != "dark" {
+		t.Errorf("ui was loaded although network failed: %+v", got)
+	}
+
+	writeTestFile(t, network.Path(), "port: 6060\n")
+	if err := r.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if ui.Config().Theme != "light" || network.Config().Port != 6060 {
+		t.Errorf("Load() = %+v, %+v", ui.Config(), network.Config())
+	}
+}
+
+func TestRegistry_SaveRollback(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	blocker := filepath.Join(tmpDir, "blocker")
+	writeTestFile(t, blocker, "")
+	r, ui, _ := newTestRegistry(t, filepath.Join(blocker, "network.yaml"))
+
+	writeTestFile(t, ui.Path(), "theme = \"original\"\n")
+	if err := r.Add("ui-copy", ui); err == nil {
+		t.Fatal("Add() of manager with the same file succeeded")
+	}
+	if err := ui.Set("theme", "changed"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := r.Save(); err == nil {
+		t.Fatal("Save() into unwritable directory succeeded")
+	}
+	data, err := os.ReadFile(ui.Path())
+	if err != nil {
+		t.Fatalf("failed to read ui config: %v", err)
+	}
+	if string(data) != "theme = \"original\"\n" {
+		t.Errorf("ui config was not rolled back:\n%s", data)
+	}
+}
+
+func TestRegistry_SaveRollbackSideEffects(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	ui, err := New("testapp", uiConfig{Theme: "dark"}, WithFileName("ui"), WithBackups(3), WithAuditLog())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	network, err := New("testapp", networkConfig{Port: 9090}, ForcePath(filepath.Join(tmpDir, "network.toml")),
+		WithFS(ReadOnly(OSFS())))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	r := NewRegistry()
+	if err := r.Add("ui", ui); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := r.Add("network", network); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+
+	writeTestFile(t, ui.Path(), "theme = \"original\"\n")
+	if err := ui.Set("theme", "changed"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	if err := r.Save(); err == nil {
+		t.Fatal("Save() into read-only filesystem succeeded")
+	}
+	if backups, err := ui.ListBackups(); err != nil || len(backups) != 0 {
+		t.Errorf("ListBackups() = %v, %v, want no backups of rolled back save", backups, err)
+	}
+	if entries, err := ui.AuditLog(); err != nil || len(entries) != 0 {
+		t.Errorf("AuditLog() = %v, %v, want no entries of rolled back save", entries, err)
+	}
+
+	r = NewRegistry()
+	if err := r.Add("ui", ui); err != nil {
+		t.Fatalf("Add() failed: %v", err)
+	}
+	if err := r.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	if backups, err := ui.ListBackups(); err != nil || len(backups) != 1 {
+		t.Errorf("ListBackups() = %v, %v, want 1 backup", backups, err)
+	}
+	if entries, err := ui.AuditLog(); err != nil || len(entries) != 1 {
+		t.Errorf("AuditLog() = %v, %v, want 1 entry", entries, err)
+	}
+}
+
+// failingSection is a section failing at chosen stage
+type failingSection struct {
+	path        string
+	saveErr     error
+	writeErr    error
+	rollbackErr error
+	rolledBack  bool
+}
+
+func (s *failingSection) Path() string                       { return s.path }
+func (s *failingSection) acquire() (func(), error)           { return func() {}, nil }
+func (s *failingSection) stageSave() (any, error)            { return nil, nil }
+func (s *failingSection) captureFile() (fileSnapshot, error) { return fileSnapshot{}, nil }
+
+func (s *failingSection) stageLoad() (any, stagedLoad, error) {
+	return nil, stagedLoad{
+		save: func() (func() error, error) { return func() error { return nil }, s.saveErr },
+		use:  func() {},
+	}, nil
+}
+
+func (s *failingSection) writeStaged() (func() error, error) {
+	return func() error { return nil }, s.writeErr
+}
+
+func (s *failingSection) rollback(fileSnapshot) error {
+	s.rolledBack = true
+	return s.rollbackErr
+}
+
+func TestRegistry_SaveRollbackContinues(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ui, err := New("testapp", uiConfig{Theme: "dark"}, WithFileName("ui"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	writeTestFile(t, ui.Path(), "theme = \"original\"\n")
+	if err := ui.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	stuck := &failingSection{path: "stuck", rollbackErr: fmt.Errorf("stuck rollback")}
+	broken := &failingSection{path: "broken", writeErr: fmt.Errorf("broken write")}
+	r := NewRegistry()
+	for _, section := range []Section{ui, stuck, broken} {
+		if err := r.Add(section.Path(), section); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	if err := ui.Set("theme", "changed"); err != nil {
+		t.Fatalf("Set() failed: %v", err)
+	}
+	err = r.Save()
+	if err == nil || !strings.Contains(err.Error(), "broken write") || !strings.Contains(err.Error(), "stuck rollback") {
+		t.Fatalf("Save() error = %v, want write and rollback errors", err)
+	}
+	if !stuck.rolledBack {
+		t.Error("failed section was not rolled back")
+	}
+	if data, _ := os.ReadFile(ui.Path()); string(data) != "theme = \"original\"\n" {
+		t.Errorf("ui config was not rolled back after failed rollback of other section:\n%s", data)
+	}
+}
+
+func TestRegistry_LoadRollback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "versioned.toml")
+	writeTestFile(t, path, "addr = \"127.0.0.1:80\"\n")
+	m, err := New("testapp", versionedConfig{ListenAddr: "default"}, ForcePath(path),
+		WithSchemaVersion(2),
+		WithMigration(1, renameKey("addr", "listen_addr")),
+	)
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	broken := &failingSection{path: "broken", saveErr: fmt.Errorf("broken save")}
+	r := NewRegistry()
+	for _, section := range []Section{m, broken} {
+		if err := r.Add(section.Path(), section); err != nil {
+			t.Fatalf("Add() failed: %v", err)
+		}
+	}
+	if err := r.Load(); err == nil {
+		t.Fatal("Load() with failing section succeeded")
+	}
+	if got := m.Config().ListenAddr; got != "default" {
+		t.Errorf("Config().ListenAddr = %v, want section to stay unchanged", got)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "addr = \"127.0.0.1:80\"\n" {
+		t.Errorf("migrated file was not rolled back:\n%s", data)
+	}
+}
@@ -0,0 +1,625 @@
+package configmanager
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+)
+
+// schemaDialect is the JSON Schema draft of generated schemas
+const schemaDialect = "http://json-schema.org/draft-07/schema#"
+
+// schemaFailure is a single schema violation found in raw config document
+type schemaFailure struct {
+	keys    []any
+	rule    string
+	message string
+}
+
+// WithSchemaValidation option validates every config file against Schema()
+// before decoding. Violations are reported as *ValidationError with file,
+// line and column of invalid values.
+func WithSchemaValidation() ManagerOption {
+	return func(mo *managerOptions) {
+		mo.schemaValidation = true
+	}
+}
+
+// Schema returns JSON Schema of config files. Defaults are taken from default
+// config, descriptions from `comment` (or `doc`) and `example` tags, enums and
+// limits from `validate` tags.
+func (m *Manager[T]) Schema() ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	schema, err := m.schema(m.format)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode schema: %v", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// ValidateFile checks config file at path against Schema() without loading it
+func (m *Manager[T]) ValidateFile(path string) error {
+	data, err := m.fs.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.checkSchema(path, m.layerFormat(path, data), data)
+}
+
+// schema builds JSON Schema document for config file of given format
+func (m *Manager[T]) schema(format SerializationFormat) (map[string]any, error) {
+	defaults := cloneConfig(m.defaults)
+	m.stampVersion(defaults)
+	data, err := encode(format, defaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode defaults: %v", err)
+	}
+	doc := make(map[string]any)
+	if err := decode(format, data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode defaults: %v", err)
+	}
+	schema := typeSchema(reflect.TypeOf((*T)(nil)).Elem(), doc, format, make(map[reflect.Type]bool))
+	if properties, ok := schema["properties"].(map[string]any); ok {
+		if _, exists := properties[IncludeKey]; !exists {
+			properties[IncludeKey] = map[string]any{
+				"type":        []string{"array", "string"},
+				"items":       map[string]any{"type": "string"},
+				"description": "Glob patterns of config fragments merged after this file",
+			}
+		}
+	}
+	schema["$schema"] = schemaDialect
+	schema["title"] = m.appName
+	return schema, nil
+}
+
+// checkSchema validates raw config data read from path against schema and
+// returns *ValidationError listing every violation with its position
+func (m *Manager[T]) checkSchema(path string, format SerializationFormat, data []byte) error {
+	schema, err := m.schema(format)
+	if err != nil {
+		return err
+	}
+	doc, lines, err := parseLineDoc(format, data)
+	if err == nil && !lines {
+		doc = make(map[string]any)
+		err = decode(format, data, &doc)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to decode %v: %v", path, err)
+	}
+	var value any = doc
+	if lines {
+		value = coerceDoc(doc, schema)
+	}
+	failures := []schemaFailure{}
+	validateSchema(value, schema, nil, &failures)
+	if len(failures) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: locateFailures(path, format, data, failures)}
+}
+
+// locateFailures converts failures found in raw config data read from path
+// into field errors with positions
+func locateFailures(path string, format SerializationFormat, data []byte, failures []schemaFailure) []FieldError {
+	locate := newLocator(format, data)
+	errs := make([]FieldError, 0, len(failures))
+	for _, failure := range failures {
+		line, column := locate(failure.keys)
+		errs = append(errs, FieldError{
+			Path:    formatKeys(failure.keys),
+			Rule:    failure.rule,
+			Message: failure.message,
+			File:    path,
+			Line:    line,
+			Column:  column,
+		})
+	}
+	return errs
+}
+
+// typeSchema returns JSON Schema of type t. def is the default value of t in
+// generic document form, it is set as default of scalar and array values.
+func typeSchema(t reflect.Type, def any, format SerializationFormat, stack map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	schema := map[string]any{}
+	switch {
+	case t == durationType:
+		schema["type"] = []string{"integer", "string"}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		schema["type"] = "string"
+	case stack[t]:
+		return schema
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			schema["type"] = "boolean"
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			schema["type"] = "integer"
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			schema["type"] = "integer"
+			schema["minimum"] = float64(0)
+		case reflect.Float32, reflect.Float64:
+			schema["type"] = "number"
+		case reflect.String:
+			schema["type"] = "string"
+		case reflect.Slice, reflect.Array:
+			if t.Elem().Kind() == reflect.Uint8 {
+				schema["type"] = "string"
+				break
+			}
+			schema["type"] = "array"
+			schema["items"] = typeSchema(t.Elem(), nil, format, stack)
+		case reflect.Map:
+			schema["type"] = "object"
+			schema["additionalProperties"] = typeSchema(t.Elem(), nil, format, stack)
+		case reflect.Struct:
+			stack[t] = true
+			defer delete(stack, t)
+			defaults, _ := def.(map[string]any)
+			properties := map[string]any{}
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				key, ok := fieldKey(field, format)
+				if !ok {
+					continue
+				}
+				properties[key] = fieldSchema(field, defaults[key], format, stack)
+			}
+			schema["type"] = "object"
+			schema["properties"] = properties
+			return schema
+		}
+	}
+	if def != nil && schema["type"] != "object" {
+		schema["default"] = def
+	}
+	return schema
+}
+
+// fieldSchema returns JSON Schema of struct field including documentation and rules.
+// Defaults and rules of secret fields are omitted as file holds ciphertext.
+func fieldSchema(field reflect.StructField, def any, format SerializationFormat, stack map[reflect.Type]bool) map[string]any {
+	secret := field.Tag.Get("secret") == "true"
+	if secret {
+		def = nil
+	}
+	schema := typeSchema(field.Type, def, format, stack)
+	description := field.Tag.Get("comment")
+	if description == "" {
+		description = field.Tag.Get("doc")
+	}
+	if description != "" {
+		schema["description"] = description
+	}
+	if example := field.Tag.Get("example"); example != "" {
+		schema["examples"] = []any{example}
+	}
+	if secret {
+		return schema
+	}
+	t := field.Type
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	rules, _ := parseRules(field.Tag.Get("validate"))
+	for _, rule := range rules {
+		switch rule.name {
+		case "required":
+			if keyword := limitKeyword(t, "min"); keyword != "" && !strings.HasPrefix(keyword, "minimum") {
+				schema[keyword] = float64(1)
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(rule.param, 64)
+			if keyword := limitKeyword(t, rule.name); err == nil && keyword != "" {
+				schema[keyword] = limit
+			}
+		case "oneof":
+			enum := []any{}
+			for _, option := range strings.Fields(rule.param) {
+				enum = append(enum, enumValue(t, option))
+			}
+			schema["enum"] = enum
+		case "url":
+			schema["format"] = "uri"
+		}
+	}
+	return schema
+}
+
+// limitKeyword returns JSON Schema keyword of min or max rule for type t
+func limitKeyword(t reflect.Type, rule string) string {
+	if t == durationType {
+		return ""
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return rule + "imum"
+	case reflect.String:
+		return rule + "Length"
+	case reflect.Slice, reflect.Array:
+		return rule + "Items"
+	case reflect.Map:
+		return rule + "Properties"
+	default:
+		return ""
+	}
+}
+
+// enumValue converts oneof option to JSON value of type t
+func enumValue(t reflect.Type, option string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(option, 64); err == nil {
+			return f
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(option); err == nil {
+			return b
+		}
+	}
+	return option
+}
+
+// validateSchema checks document value against schema and appends violations to failures
+func validateSchema(value any, schema map[string]any, keys []any, failures *[]schemaFailure) {
+	fail := func(rule, format string, args ...any) {
+		*failures = append(*failures, schemaFailure{keys: keys, rule: rule, message: fmt.Sprintf(format, args...)})
+	}
+	actual := jsonType(value)
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !typeMatches(types, actual) {
+		fail("type", "expected %v, got %v", strings.Join(types, " or "), actual)
+		return
+	}
+	if enum, ok := schema["enum"].([]any); ok && !inEnum(value, enum) {
+		options := make([]string, 0, len(enum))
+		for _, option := range enum {
+			options = append(options, fmt.Sprint(option))
+		}
+		fail("enum", "'%v' is not one of [%v]", value, strings.Join(options, ", "))
+	}
+	if number, ok := toFloat(value); ok {
+		if limit, ok := schema["minimum"].(float64); ok && number < limit {
+			fail("minimum", "value %v is less than %v", formatNumber(number), formatNumber(limit))
+		}
+		if limit, ok := schema["maximum"].(float64); ok && number > limit {
+			fail("maximum", "value %v is greater than %v", formatNumber(number), formatNumber(limit))
+		}
+	}
+	v := reflect.ValueOf(value)
+	var suffix string
+	var length int
+	switch actual {
+	case "string":
+		suffix, length = "Length", utf8.RuneCountInString(fmt.Sprint(value))
+	case "array":
+		suffix, length = "Items", v.Len()
+	case "object":
+		suffix, length = "Properties", v.Len()
+	}
+	if suffix != "" {
+		if limit, ok := schema["min"+suffix].(float64); ok && float64(length) < limit {
+			fail("min"+suffix, "length %v is less than %v", length, formatNumber(limit))
+		}
+		if limit, ok := schema["max"+suffix].(float64); ok && float64(length) > limit {
+			fail("max"+suffix, "length %v is greater than %v", length, formatNumber(limit))
+		}
+	}
+	switch actual {
+	case "object":
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		names, children := mapEntries(v)
+		for _, name := range names {
+			child := additional
+			if property, ok := lookupProperty(properties, name); ok {
+				child = property
+			}
+			if child != nil {
+				validateSchema(children[name], child, append(append([]any{}, keys...), name), failures)
+			}
+		}
+	case "array":
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i := 0; i < v.Len(); i++ {
+				validateSchema(v.Index(i).Interface(), items, append(append([]any{}, keys...), i), failures)
+			}
+		}
+	}
+}
+
+// coerceDoc converts string values of line based codecs into types allowed
+// by schema. Values which can not be converted are kept as strings.
+func coerceDoc(value any, schema map[string]any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		additional, _ := schema["additionalProperties"].(map[string]any)
+		coerced := make(map[string]any, len(value))
+		for name, item := range value {
+			child := additional
+			if property, ok := lookupProperty(properties, name); ok {
+				child = property
+			}
+			coerced[name] = coerceDoc(item, child)
+		}
+		return coerced
+	case quoted:
+		return coerceText(string(value), schema)
+	case string:
+		return coerceText(value, schema)
+	default:
+		return value
+	}
+}
+
+// coerceText converts text into first type of schema it can be parsed as
+func coerceText(text string, schema map[string]any) any {
+	types := schemaTypes(schema["type"])
+	for _, t := range types {
+		if t == "string" {
+			return text
+		}
+	}
+	for _, t := range types {
+		switch t {
+		case "boolean":
+			if b, err := strconv.ParseBool(text); err == nil {
+				return b
+			}
+		case "integer":
+			if i, err := strconv.ParseInt(text, 0, 64); err == nil {
+				return i
+			}
+		case "number":
+			if f, err := strconv.ParseFloat(text, 64); err == nil {
+				return f
+			}
+		case "array":
+			items, _ := schema["items"].(map[string]any)
+			list := []any{}
+			for _, item := range splitList(text) {
+				list = append(list, coerceText(item, items))
+			}
+			return list
+		}
+	}
+	return text
+}
+
+// mapEntries returns sorted keys and values of decoded document map
+func mapEntries(v reflect.Value) ([]string, map[string]any) {
+	names := make([]string, 0, v.Len())
+	children := make(map[string]any, v.Len())
+	for _, key := range v.MapKeys() {
+		name := fmt.Sprint(key.Interface())
+		names = append(names, name)
+		children[name] = v.MapIndex(key).Interface()
+	}
+	sort.Strings(names)
+	return names, children
+}
+
+// lookupProperty returns schema of property matching name, falling back to
+// case insensitive match as decoders do
+func lookupProperty(properties map[string]any, name string) (map[string]any, bool) {
+	if property, ok := properties[name].(map[string]any); ok {
+		return property, true
+	}
+	for key, property := range properties {
+		if strings.EqualFold(key, name) {
+			property, ok := property.(map[string]any)
+			return property, ok
+		}
+	}
+	return nil, false
+}
+
+// jsonType returns JSON Schema type name of decoded document value
+func jsonType(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string, encoding.TextMarshaler:
+		return "string"
+	}
+	if f, ok := toFloat(value); ok {
+		if f == math.Trunc(f) && !math.IsInf(f, 0) {
+			return "integer"
+		}
+		return "number"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Map:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// schemaTypes returns allowed types of "type" keyword
+func schemaTypes(raw any) []string {
+	switch types := raw.(type) {
+	case string:
+		return []string{types}
+	case []string:
+		return types
+	default:
+		return nil
+	}
+}
+
+// typeMatches reports whether actual type is allowed. Integers are numbers.
+func typeMatches(types []string, actual string) bool {
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// inEnum reports whether value equals one of enum options
+func inEnum(value any, enum []any) bool {
+	for _, option := range enum {
+		a, aIsNumber := toFloat(value)
+		b, bIsNumber := toFloat(option)
+		if aIsNumber && bIsNumber && a == b || fmt.Sprint(value) == fmt.Sprint(option) {
+			return true
+		}
+	}
+	return false
+}
+
+// toFloat converts decoded number to float64
+func toFloat(raw any) (float64, bool) {
+	v := reflect.ValueOf(raw)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+// formatKeys builds dotted key path with [i] indexes
+func formatKeys(keys []any) string {
+	path := ""
+	for _, key := range keys {
+		if i, ok := key.(int); ok {
+			path = fmt.Sprintf("%v[%v]", path, i)
+			continue
+		}
+		path = joinPath(path, fmt.Sprint(key))
+	}
+	return path
+}
+
+// newLocator returns function finding line and column of value at key path
+// in raw config data. Zero position is returned if value can not be found.
+func newLocator(format SerializationFormat, data []byte) func(keys []any) (int, int) {
+	data = stripBOM(data)
+	switch format {
+	case TOML, INI:
+		return func(keys []any) (int, int) {
+			return locateTOML(data, keys)
+		}
+	case JSON:
+		data = stripJSONComments(data)
+	case JSON5:
+		data, _ = json5ToJSON(data)
+	case YAML:
+	default:
+		return func([]any) (int, int) {
+			return 0, 0
+		}
+	}
+	file, err := parser.ParseBytes(data, 0)
+	return func(keys []any) (int, int) {
+		if err != nil {
+			return 0, 0
+		}
+		return locateYAML(file, keys)
+	}
+}
+
+// locateYAML returns position of the deepest node found on key path in
+// parsed YAML (or JSON) document
+func locateYAML(file *ast.File, keys []any) (int, int) {
+	for n := len(keys); n > 0; n-- {
+		builder := (&yaml.PathBuilder{}).Root()
+		for _, key := range keys[:n] {
+			if i, ok := key.(int); ok {
+				builder = builder.Index(uint(i))
+			} else {
+				builder = builder.Child(fmt.Sprint(key))
+			}
+		}
+		node, err := builder.Build().FilterFile(file)
+		if err == nil && node != nil && node.GetToken() != nil {
+			position := node.GetToken().Position
+			return position.Line, position.Column
+		}
+	}
+	return 0, 0
+}
+
+// locateTOML returns position of the key line or table header deepest on key path
+func locateTOML(data []byte, keys []any) (int, int) {
+	want := make([]string, 0, len(keys))
+	for _, key := range keys {
+		want = append(want, fmt.Sprint(key))
+	}
+	bestLine, bestColumn, bestDepth := 0, 0, 0
+	table := []string{}
+	arrays := make(map[string]int)
+	for i, line := range strings.Split(string(data), "\n") {
+		var found []string
+		var indent string
+		if match := tomlTableLine.FindStringSubmatch(line); match != nil {
+			table = []string{}
+			for _, key := range strings.Split(match[2], ".") {
+				table = append(table, unquoteKey(strings.TrimSpace(key)))
+			}
+			if strings.HasPrefix(strings.TrimSpace(line), "[[") {
+				name := strings.Join(table, ".")
+				table = append(table, strconv.Itoa(arrays[name]))
+				arrays[name]++
+			}
+			found, indent = table, match[1]
+		} else if match := tomlKeyLine.FindStringSubmatch(line); match != nil {
+			found, indent = append(append([]string{}, table...), unquoteKey(match[2])), match[1]
+		} else {
+			continue
+		}
+		if len(found) > bestDepth && isKeyPrefix(found, want) {
+			bestLine, bestColumn, bestDepth = i+1, len(indent)+1, len(found)
+		}
+	}
+	return bestLine, bestColumn
+}
+
+// isKeyPrefix reports whether prefix is the beginning of keys
+func isKeyPrefix(prefix, keys []string) bool {
+	if len(prefix) > len(keys) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != keys[i] {
+			return false
+		}
+	}
+	return true
+}
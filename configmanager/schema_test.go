@@ -0,0 +1,179 @@
+package configmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type schemaConfig struct {
+	Level  string     `toml:"level" yaml:"level" json:"level" validate:"oneof=debug info" comment:"Log level"`
+	Tags   []string   `toml:"tags" yaml:"tags" json:"tags" validate:"max=2"`
+	Server testServer `toml:"server" yaml:"server" json:"server"`
+}
+
+func defaultSchemaConfig() schemaConfig {
+	return schemaConfig{Level: "info", Server: testServer{Host: "localhost", Port: 8080}}
+}
+
+func TestSchema(t *testing.T) {
+	m, err := New("testapp", defaultSchemaConfig(), ForcePath(filepath.Join(t.TempDir(), "config.toml")))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	data, err := m.Schema()
+	if err != nil {
+		t.Fatalf("Schema() failed: %v", err)
+	}
+	var schema struct {
+		Schema     string `json:"$schema"`
+		Properties struct {
+			Level struct {
+				Type        string `json:"type"`
+				Description string `json:"description"`
+				Default     string `json:"default"`
+				Enum        []string
+			} `json:"level"`
+			Tags struct {
+				MaxItems float64 `json:"maxItems"`
+			} `json:"tags"`
+			Server struct {
+				Properties struct {
+					Port struct {
+						Type    string  `json:"type"`
+						Default float64 `json:"default"`
+					} `json:"port"`
+				} `json:"properties"`
+			} `json:"server"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("Schema() returned invalid JSON: %v", err)
+	}
+	level := schema.Properties.Level
+	if schema.Schema != schemaDialect || level.Type != "string" || level.Description != "Log level" ||
+		level.Default != "info" || len(level.Enum) != 2 {
+		t.Errorf("Schema() level = %+v", level)
+	}
+	if schema.Properties.Tags.MaxItems != 2 {
+		t.Errorf("Schema() tags.maxItems = %v, want 2", schema.Properties.Tags.MaxItems)
+	}
+	if port := schema.Properties.Server.Properties.Port; port.Type != "integer" || port.Default != 8080 {
+		t.Errorf("Schema() server.port = %+v", port)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []FieldError
+	}{
+		{
+			name:    "toml",
+			file:    "config.toml",
+			content: "level = \"info\"\n\n[server]\n  host = \"localhost\"\n  port = \"http\"\n",
+			want:    []FieldError{{Path: "server.port", Rule: "type", Line: 5, Column: 3}},
+		},
+		{
+			name:    "yaml",
+			file:    "config.yaml",
+			content: "level: trace\ntags: [a, b, c]\nserver:\n  port: 80\n",
+			want: []FieldError{
+				{Path: "level", Rule: "enum", Line: 1, Column: 8},
+				{Path: "tags", Rule: "maxItems", Line: 2, Column: 7},
+			},
+		},
+		{
+			name:    "json",
+			file:    "config.json",
+			content: "{\n  \"server\": {\n    \"port\": true\n  }\n}\n",
+			want:    []FieldError{{Path: "server.port", Rule: "type", Line: 3, Column: 13}},
+		},
+		{
+			name:    "valid",
+			file:    "config.yaml",
+			content: "level: debug\nserver:\n  port: 80\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeTestFile(t, path, tt.content)
+			m, err := New("testapp", defaultSchemaConfig(), ForcePath(path), WithAutoFormat())
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			err = m.ValidateFile(path)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("ValidateFile() failed: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("ValidateFile() error = %v, want *ValidationError", err)
+			}
+			if len(verr.Fields) != len(tt.want) {
+				t.Fatalf("ValidateFile() = %v, want %v errors", verr, len(tt.want))
+			}
+			for i, want := range tt.want {
+				got := verr.Fields[i]
+				if got.Path != want.Path || got.Rule != want.Rule || got.Line != want.Line || got.Column != want.Column || got.File != path {
+					t.Errorf("field error %v = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadWithSchemaValidation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestFile(t, path, "server:\n  port: many\n")
+	m, err := New("testapp", defaultSchemaConfig(), ForcePath(path), WithAutoFormat(), WithSchemaValidation())
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	var verr *ValidationError
+	if err := m.Load(); !errors.As(err, &verr) || verr.Fields[0].Line != 2 {
+		t.Fatalf("Load() error = %v, want schema error at line 2", err)
+	}
+}
+
+func TestLoadWithSchemaValidation_LineFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		wantErr string
+	}{
+		{name: "ini", file: "config.ini", content: "level = debug\ntags = a,b\n\n[server]\nhost = 123\nport = 80\ntimeout = 5s\n"},
+		{name: "dotenv", file: "config.env", content: "LEVEL=info\nTAGS=a\nSERVER__HOST=true\nSERVER__PORT=0x50\n"},
+		{name: "ini invalid integer", file: "config.ini", content: "[server]\nport = many\n", wantErr: "type"},
+		{name: "ini too many items", file: "config.ini", content: "tags = a,b,c\n", wantErr: "maxItems"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeTestFile(t, path, tt.content)
+			m, err := New("testapp", defaultSchemaConfig(), ForcePath(path), WithAutoFormat(), WithSchemaValidation())
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			err = m.Load()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() failed: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) || verr.Fields[0].Rule != tt.wantErr {
+				t.Fatalf("Load() error = %v, want %v schema error", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package configmanager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	// RedactedValue replaces secret values in String output
+	RedactedValue = "******"
+	// secretPrefix marks encrypted values in config files
+	secretPrefix = "enc:v1:"
+	// secretKeySize is the size of AES-256 key
+	secretKeySize = 32
+)
+
+// WithKeyFile option sets path to the key file used to encrypt secret fields.
+// By default key is stored as secret.key in the application XDG data directory.
+func WithKeyFile(path string) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.keyPath = path
+	}
+}
+
+// hasSecrets reports whether type t has fields tagged with `secret:"true"`.
+// Secret fields must be strings.
+func hasSecrets(t reflect.Type, visited map[reflect.Type]bool) (bool, error) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visited[t] {
+		return false, nil
+	}
+	visited[t] = true
+	found := false
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !embeddedStruct(field) {
+			continue
+		}
+		if field.Tag.Get("secret") == "true" {
+			if field.Type.Kind() != reflect.String {
+				return false, fmt.Errorf("secret field %v.%v must be string", t.Name(), field.Name)
+			}
+			found = true
+			continue
+		}
+		nested, err := hasSecrets(field.Type, visited)
+		if err != nil {
+			return false, err
+		}
+		found = found || nested
+	}
+	return found, nil
+}
+
+// embeddedStruct reports whether field embeds struct value. Codecs promote
+// exported fields of such structs even if the struct type is unexported.
+func embeddedStruct(field reflect.StructField) bool {
+	return field.Anonymous && field.Type.Kind() == reflect.Struct
+}
+
+// walkSecrets calls fn for every secret field of v and matching field of old
+// (old may be invalid if there is no counterpart)
+func walkSecrets(v, old reflect.Value, fn func(field, old reflect.Value) error) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		if old.IsValid() && old.Kind() == reflect.Pointer && !old.IsNil() {
+			old = old.Elem()
+		} else {
+			old = reflect.Value{}
+		}
+		return walkSecrets(v.Elem(), old, fn)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			var oldElem reflect.Value
+			if old.IsValid() && i < old.Len() {
+				oldElem = old.Index(i)
+			}
+			if err := walkSecrets(v.Index(i), oldElem, fn); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		// map values are not addressable, so they are walked as copies and stored back
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			var oldElem reflect.Value
+			if old.IsValid() && !old.IsNil() {
+				oldElem = old.MapIndex(iter.Key())
+			}
+			if err := walkSecrets(elem, oldElem, fn); err != nil {
+				return fmt.Errorf("%v: %v", iter.Key(), err)
+			}
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			if !field.IsExported() && !embeddedStruct(field) {
+				continue
+			}
+			var oldField reflect.Value
+			if old.IsValid() {
+				oldField = old.Field(i)
+			}
+			if field.Tag.Get("secret") == "true" && field.Type.Kind() == reflect.String {
+				if err := fn(v.Field(i), oldField); err != nil {
+					return fmt.Errorf("secret %v: %v", field.Name, err)
+				}
+				continue
+			}
+			if err := walkSecrets(v.Field(i), oldField, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// secretKey reads key file, creating it if create is true and file does not exist
+func (m *Manager[T]) secretKey(create bool) ([]byte, error) {
+	data, err := m.fs.ReadFile(m.keyPath)
+	if err == nil {
+		key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(key) != secretKeySize {
+			return nil, fmt.Errorf("invalid key file %v", m.keyPath)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, fmt.Errorf("failed to read key file: %v", err)
+	}
+	key := make([]byte, secretKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	if err := m.fs.MkdirAll(filepath.Dir(m.keyPath), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create key directory: %w", err)
+	}
+	if err := m.fs.WriteFile(m.keyPath, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, fmt.Errorf("failed to save key file: %w", err)
+	}
+	return key, nil
+}
+
+// sealSecrets encrypts secret fields of cfg in place. Ciphertext from file at
+// config path is reused for values which did not change.
+func (m *Manager[T]) sealSecrets(cfg *T) error {
+	if !m.secrets {
+		return nil
+	}
+	key, err := m.secretKey(true)
+	if err != nil {
+		return err
+	}
+	var old reflect.Value
+	if data, err := m.fs.ReadFile(m.path); err == nil {
+		onDisk := new(T)
+		if err := decode(m.layerFormat(m.path, data), data, onDisk); err == nil {
+			old = reflect.ValueOf(onDisk).Elem()
+		}
+	}
+	return walkSecrets(reflect.ValueOf(cfg).Elem(), old, func(field, old reflect.Value) error {
+		plain := field.String()
+		if plain == "" || strings.HasPrefix(plain, secretPrefix) {
+			return nil
+		}
+		if old.IsValid() && strings.HasPrefix(old.String(), secretPrefix) {
+			if decrypted, err := decryptSecret(key, old.String()); err == nil && decrypted == plain {
+				field.SetString(old.String())
+				return nil
+			}
+		}
+		sealed, err := encryptSecret(key, plain)
+		if err != nil {
+			return err
+		}
+		field.SetString(sealed)
+		return nil
+	})
+}
+
+// openSecrets decrypts encrypted secret fields of cfg in place
+func (m *Manager[T]) openSecrets(cfg *T) error {
+	if !m.secrets {
+		return nil
+	}
+	var key []byte
+	return walkSecrets(reflect.ValueOf(cfg).Elem(), reflect.Value{}, func(field, _ reflect.Value) error {
+		if !strings.HasPrefix(field.String(), secretPrefix) {
+			return nil
+		}
+		if key == nil {
+			k, err := m.secretKey(false)
+			if err != nil {
+				return err
+			}
+			key = k
+		}
+		plain, err := decryptSecret(key, field.String())
+		if err != nil {
+			return err
+		}
+		field.SetString(plain)
+		return nil
+	})
+}
+
+// redactSecrets replaces non empty secret fields of cfg with RedactedValue
+func redactSecrets[T any](cfg *T) {
+	walkSecrets(reflect.ValueOf(cfg).Elem(), reflect.Value{}, func(field, _ reflect.Value) error {
+		if field.String() != "" {
+			field.SetString(RedactedValue)
+		}
+		return nil
+	})
+}
+
+// Redacted returns copy of current configuration with secret fields redacted
+func (m *Manager[T]) Redacted() T {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cfg := cloneConfig(m.config)
+	redactSecrets(cfg)
+	return *cfg
+}
+
+// String returns current configuration serialized in its format with secret fields redacted
+func (m *Manager[T]) String() string {
+	cfg := m.Redacted()
+	data, err := m.marshal(&cfg)
+	if err != nil {
+		return fmt.Sprintf("<failed to marshal config: %v>", err)
+	}
+	return string(data)
+}
+
+// filePerm returns permissions for config file
+func (m *Manager[T]) filePerm() os.FileMode {
+	if m.secrets {
+		return 0600
+	}
+	return 0644
+}
+
+// encryptSecret encrypts plain text with AES-GCM
+func encryptSecret(key []byte, plain string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret decrypts value produced by encryptSecret
+func decryptSecret(key []byte, value string) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return "", fmt.Errorf("malformed encrypted value")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt value: %v", err)
+	}
+	return string(plain), nil
+}
+
+// newGCM creates AES-GCM cipher from key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,177 @@
+package configmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secretConfig struct {
+	User  string `toml:"user"`
+	Token string `toml:"token" secret:"true"`
+	API   struct {
+		Key string `toml:"key" secret:"true"`
+	} `toml:"api"`
+}
+
+func TestSecrets(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.toml")
+	keyPath := filepath.Join(tmpDir, "data", "secret.key")
+	cfg := secretConfig{User: "admin", Token: "s3cr3t-token"}
+	cfg.API.Key = "api-key"
+
+	m, err := New("testapp", cfg, ForcePath(path), WithKeyFile(keyPath))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	for _, plain := range []string{"s3cr3t-token", "api-key"} {
+		if strings.Contains(string(data), plain) {
+			t.Errorf("config file contains plaintext secret %q:\n%s", plain, data)
+		}
+	}
+	for _, p := range []string{path, keyPath} {
+		info, err := os.Stat(p)
+		if err != nil {
+			t.Fatalf("failed to stat %v: %v", p, err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("%v permissions = %o, want 600", p, perm)
+		}
+	}
+
+	if err := m.Save(); err != nil {
+		t.Fatalf("second Save() failed: %v", err)
+	}
+	again, _ := os.ReadFile(path)
+	if string(again) != string(data) {
+		t.Errorf("unchanged secrets were re-encrypted:\n%s\n%s", data, again)
+	}
+
+	loaded, err := New("testapp", secretConfig{}, ForcePath(path), WithKeyFile(keyPath))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := loaded.Config(); got != cfg {
+		t.Errorf("Config() = %+v, want %+v", got, cfg)
+	}
+
+	out := loaded.String()
+	if strings.Contains(out, "s3cr3t-token") || strings.Contains(out, "api-key") || !strings.Contains(out, RedactedValue) {
+		t.Errorf("String() is not redacted:\n%s", out)
+	}
+	if got := loaded.Redacted(); got.Token != RedactedValue || got.User != "admin" {
+		t.Errorf("Redacted() = %+v", got)
+	}
+}
+
+func TestNew_SecretMustBeString(t *testing.T) {
+	type badConfig struct {
+		Pin int `secret:"true"`
+	}
+	if _, err := New("testapp", badConfig{}, ForcePath(filepath.Join(t.TempDir(), "config.toml"))); err == nil {
+		t.Error("New() succeeded unexpectedly")
+	}
+}
+
+func TestSecrets_Map(t *testing.T) {
+	type cred struct {
+		User     string `toml:"user"`
+		Password string `toml:"password" secret:"true"`
+	}
+	type mapConfig struct {
+		Creds map[string]cred `toml:"creds"`
+	}
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.toml")
+	keyPath := filepath.Join(tmpDir, "secret.key")
+	cfg := mapConfig{Creds: map[string]cred{"db": {User: "admin", Password: "db-pass"}}}
+
+	m, err := New("testapp", cfg, ForcePath(path), WithKeyFile(keyPath))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if strings.Contains(string(data), "db-pass") || !strings.Contains(string(data), secretPrefix) {
+		t.Errorf("config file contains plaintext secret:\n%s", data)
+	}
+	if got := m.Config().Creds["db"].Password; got != "db-pass" {
+		t.Errorf("Save() changed secret in memory to %q", got)
+	}
+
+	loaded, err := New("testapp", mapConfig{}, ForcePath(path), WithKeyFile(keyPath))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := loaded.Config().Creds["db"]; got != cfg.Creds["db"] {
+		t.Errorf("Config().Creds[db] = %+v, want %+v", got, cfg.Creds["db"])
+	}
+	if got := loaded.Redacted().Creds["db"]; got.Password != RedactedValue || got.User != "admin" {
+		t.Errorf("Redacted().Creds[db] = %+v", got)
+	}
+}
+
+func TestSecrets_Embedded(t *testing.T) {
+	type credentials struct {
+		User     string `json:"user"`
+		Password string `json:"password" secret:"true"`
+	}
+	type embeddedConfig struct {
+		credentials
+		Name string `json:"name"`
+	}
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "config.json")
+	keyPath := filepath.Join(tmpDir, "secret.key")
+	cfg := embeddedConfig{credentials: credentials{User: "admin", Password: "db-pass"}, Name: "app"}
+
+	m, err := New("testapp", cfg, ForcePath(path), WithSerializationFormat(JSON), WithKeyFile(keyPath))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Save(); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read config: %v", err)
+	}
+	if strings.Contains(string(data), "db-pass") || !strings.Contains(string(data), secretPrefix) {
+		t.Errorf("config file contains plaintext secret:\n%s", data)
+	}
+
+	loaded, err := New("testapp", embeddedConfig{}, ForcePath(path), WithSerializationFormat(JSON), WithKeyFile(keyPath))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	if got := loaded.Config(); got != cfg {
+		t.Errorf("Config() = %+v, want %+v", got, cfg)
+	}
+	if got, err := loaded.GetRedacted("password"); err != nil || got != RedactedValue {
+		t.Errorf("GetRedacted(password) = %v, %v, want %v", got, err, RedactedValue)
+	}
+}
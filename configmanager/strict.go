@@ -0,0 +1,122 @@
+package configmanager
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// UnknownKeyMode defines how keys of config files missing in T are handled
+type UnknownKeyMode int
+
+const (
+	// UnknownKeysIgnore silently ignores unknown keys
+	UnknownKeysIgnore UnknownKeyMode = iota
+	// UnknownKeysWarn loads config and reports unknown keys by Warnings
+	UnknownKeysWarn
+	// UnknownKeysReject fails Load with *ValidationError listing unknown keys
+	UnknownKeysReject
+)
+
+// WithUnknownKeys option sets how keys of config files which do not exist in T
+// are handled. Every unknown key is reported with "did you mean" suggestion.
+func WithUnknownKeys(mode UnknownKeyMode) ManagerOption {
+	return func(mo *managerOptions) {
+		mo.unknownKeys = mode
+	}
+}
+
+// Warnings returns unknown keys found by the last Load in UnknownKeysWarn mode
+func (m *Manager[T]) Warnings() []FieldError {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]FieldError{}, m.warnings...)
+}
+
+// checkUnknownKeys returns field errors for keys of raw config data read from
+// path which do not exist in T
+func (m *Manager[T]) checkUnknownKeys(path string, format SerializationFormat, data []byte) ([]FieldError, error) {
+	doc := make(map[string]any)
+	if err := decode(format, data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %v: %v", path, err)
+	}
+	delete(doc, IncludeKey)
+	failures := []schemaFailure{}
+	findUnknownKeys(reflect.TypeOf((*T)(nil)).Elem(), doc, format, nil, &failures)
+	if len(failures) == 0 {
+		return nil, nil
+	}
+	return locateFailures(path, format, data, failures), nil
+}
+
+// findUnknownKeys walks document value and appends keys missing in type t to failures
+func findUnknownKeys(t reflect.Type, value any, format SerializationFormat, keys []any, failures *[]schemaFailure) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == durationType || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case t.Kind() == reflect.Struct && v.Kind() == reflect.Map:
+		names, children := mapEntries(v)
+		for _, name := range names {
+			childKeys := append(append([]any{}, keys...), name)
+			index, ok := findField(t, name, format)
+			if !ok {
+				message := "unknown key"
+				if suggestion := suggestKey(t, name, format); suggestion != "" {
+					message += fmt.Sprintf(" (did you mean '%v'?)", suggestion)
+				}
+				*failures = append(*failures, schemaFailure{keys: childKeys, rule: "unknown", message: message})
+				continue
+			}
+			findUnknownKeys(t.FieldByIndex(index).Type, children[name], format, childKeys, failures)
+		}
+	case t.Kind() == reflect.Map && v.Kind() == reflect.Map:
+		names, children := mapEntries(v)
+		for _, name := range names {
+			findUnknownKeys(t.Elem(), children[name], format, append(append([]any{}, keys...), name), failures)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && v.Kind() == reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			findUnknownKeys(t.Elem(), v.Index(i).Interface(), format, append(append([]any{}, keys...), i), failures)
+		}
+	}
+}
+
+// suggestKey returns key of struct t closest to name or empty string if
+// there is no similar key
+func suggestKey(t reflect.Type, name string, format SerializationFormat) string {
+	best, bestDistance := "", 0
+	for _, field := range keyFields(t, format) {
+		distance := editDistance(strings.ToLower(name), strings.ToLower(field.key))
+		if distance <= max(2, len(field.key)/3) && (best == "" || distance < bestDistance) {
+			best, bestDistance = field.key, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		current := make([]int, len(br)+1)
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			current[j] = min(prev[j]+1, current[j-1]+1, prev[j-1]+cost)
+		}
+		prev = current
+	}
+	return prev[len(br)]
+}
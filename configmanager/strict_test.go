@@ -0,0 +1,88 @@
+package configmanager
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []FieldError
+	}{
+		{
+			name:    "toml",
+			file:    "config.toml",
+			content: "name = \"app\"\n\n[server]\n  hots = \"example.com\"\n",
+			want:    []FieldError{{Path: "server.hots", Message: "unknown key (did you mean 'host'?)", Line: 4, Column: 3}},
+		},
+		{
+			name:    "yaml",
+			file:    "config.yaml",
+			content: "name: app\ndebugging: true\nserver:\n  port: 80\n  listen: x\n",
+			want: []FieldError{
+				{Path: "debugging", Message: "unknown key", Line: 2, Column: 12},
+				{Path: "server.listen", Message: "unknown key", Line: 5, Column: 11},
+			},
+		},
+		{
+			name:    "json",
+			file:    "config.json",
+			content: "{\n  \"nmae\": \"app\",\n  \"tags\": [\"a\"]\n}\n",
+			want:    []FieldError{{Path: "nmae", Message: "unknown key (did you mean 'name'?)", Line: 2, Column: 11}},
+		},
+		{
+			name:    "known keys",
+			file:    "config.yaml",
+			content: "Name: app\nserver:\n  port: 80\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			writeTestFile(t, path, tt.content)
+
+			strict, err := New("testapp", defaultTestConfig(), ForcePath(path), WithAutoFormat(), WithUnknownKeys(UnknownKeysReject))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			err = strict.Load()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Load() failed: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Load() error = %v, want *ValidationError", err)
+			}
+			checkFieldErrors(t, verr.Fields, tt.want)
+
+			lenient, err := New("testapp", defaultTestConfig(), ForcePath(path), WithAutoFormat(), WithUnknownKeys(UnknownKeysWarn))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := lenient.Load(); err != nil {
+				t.Fatalf("Load() in lenient mode failed: %v", err)
+			}
+			checkFieldErrors(t, lenient.Warnings(), tt.want)
+		})
+	}
+}
+
+func checkFieldErrors(t *testing.T, got, want []FieldError) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v errors %+v, want %v", len(got), got, len(want))
+	}
+	for i := range want {
+		if got[i].Path != want[i].Path || got[i].Message != want[i].Message ||
+			got[i].Line != want[i].Line || got[i].Column != want[i].Column {
+			t.Errorf("error %v = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
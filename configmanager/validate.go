@@ -0,0 +1,265 @@
+package configmanager
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// FieldError describes single failed validation rule. File, Line and Column
+// are set for errors found in raw config files by schema validation.
+type FieldError struct {
+	Path    string
+	Rule    string
+	Message string
+	File    string
+	Line    int
+	Column  int
+}
+
+// ValidationError lists every field which failed declarative validation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// validationRule is a parsed rule of `validate` tag
+type validationRule struct {
+	name  string
+	param string
+}
+
+// knownRules maps rule names to flag whether parameter is required
+var knownRules = map[string]bool{
+	"required":    false,
+	"min":         true,
+	"max":         true,
+	"oneof":       true,
+	"path_exists": false,
+	"url":         false,
+}
+
+// Error implements the error interface for FieldError
+func (e FieldError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%v:%v:%v: %v: %v", e.File, e.Line, e.Column, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%v: %v", e.Path, e.Message)
+}
+
+// Error implements the error interface for ValidationError
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Error())
+	}
+	return fmt.Sprintf("%v field(s) failed validation: %v", len(e.Fields), strings.Join(messages, "; "))
+}
+
+// parseRules parses `validate` tag value
+func parseRules(tag string) ([]validationRule, error) {
+	rules := []validationRule{}
+	if tag == "" {
+		return rules, nil
+	}
+	for _, part := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(part), "=")
+		needParam, ok := knownRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown validation rule '%v'", name)
+		}
+		if needParam && param == "" {
+			return nil, fmt.Errorf("validation rule '%v' requires parameter", name)
+		}
+		rules = append(rules, validationRule{name: name, param: param})
+	}
+	return rules, nil
+}
+
+// checkRules verifies `validate` tags of type t
+func checkRules(t reflect.Type, visited map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visited[t] {
+		return nil
+	}
+	visited[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if _, err := parseRules(field.Tag.Get("validate")); err != nil {
+			return fmt.Errorf("field %v.%v: %v", t.Name(), field.Name, err)
+		}
+		if err := checkRules(field.Type, visited); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ruleContext holds manager settings used by validation rules
+type ruleContext struct {
+	format SerializationFormat
+	// fs is filesystem checked by path_exists rule
+	fs FS
+}
+
+// validateTags checks cfg against `validate` tags and returns *ValidationError
+// listing all failed fields or nil
+func validateTags(cfg any, ctx ruleContext) error {
+	errs := []FieldError{}
+	collectFieldErrors(reflect.ValueOf(cfg), ctx, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: errs}
+}
+
+// collectFieldErrors walks v recursively and appends failed rules to errs
+func collectFieldErrors(v reflect.Value, ctx ruleContext, path string, errs *[]FieldError) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			collectFieldErrors(v.Elem(), ctx, path, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectFieldErrors(v.Index(i), ctx, fmt.Sprintf("%v[%v]", path, i), errs)
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			collectFieldErrors(v.MapIndex(key), ctx, joinPath(path, fmt.Sprint(key)), errs)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			key, ok := fieldKey(field, ctx.format)
+			if !ok {
+				continue
+			}
+			fieldPath := joinPath(path, key)
+			rules, _ := parseRules(field.Tag.Get("validate"))
+			for _, rule := range rules {
+				if msg := applyRule(v.Field(i), rule, ctx); msg != "" {
+					*errs = append(*errs, FieldError{Path: fieldPath, Rule: rule.name, Message: msg})
+				}
+			}
+			collectFieldErrors(v.Field(i), ctx, fieldPath, errs)
+		}
+	}
+}
+
+// applyRule checks value against rule and returns failure message or empty string
+func applyRule(v reflect.Value, rule validationRule, ctx ruleContext) string {
+	if rule.name == "required" {
+		if v.IsZero() {
+			return "is required"
+		}
+		return ""
+	}
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch rule.name {
+	case "min", "max":
+		limit, err := strconv.ParseFloat(rule.param, 64)
+		if err != nil {
+			return fmt.Sprintf("invalid %v parameter '%v'", rule.name, rule.param)
+		}
+		value, isLength, ok := measure(v)
+		if !ok {
+			return fmt.Sprintf("rule %v is not applicable to %v", rule.name, v.Kind())
+		}
+		what := "value"
+		if isLength {
+			what = "length"
+		}
+		if rule.name == "min" && value < limit {
+			return fmt.Sprintf("%v %v is less than %v", what, formatNumber(value), rule.param)
+		}
+		if rule.name == "max" && value > limit {
+			return fmt.Sprintf("%v %v is greater than %v", what, formatNumber(value), rule.param)
+		}
+	case "oneof":
+		options := strings.Fields(rule.param)
+		value := fmt.Sprint(v.Interface())
+		for _, option := range options {
+			if value == option {
+				return ""
+			}
+		}
+		return fmt.Sprintf("'%v' is not one of [%v]", value, strings.Join(options, ", "))
+	case "path_exists":
+		if v.Kind() != reflect.String {
+			return fmt.Sprintf("rule %v is not applicable to %v", rule.name, v.Kind())
+		}
+		if v.String() == "" {
+			return ""
+		}
+		if _, err := ctx.fs.Stat(v.String()); err != nil {
+			return fmt.Sprintf("path '%v' does not exist", v.String())
+		}
+	case "url":
+		if v.Kind() != reflect.String {
+			return fmt.Sprintf("rule %v is not applicable to %v", rule.name, v.Kind())
+		}
+		if v.String() == "" {
+			return ""
+		}
+		u, err := url.Parse(v.String())
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Sprintf("'%v' is not a valid url", v.String())
+		}
+	}
+	return ""
+}
+
+// measure returns numeric value of v or length of string, slice or map
+func measure(v reflect.Value) (float64, bool, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true, true
+	default:
+		return 0, false, false
+	}
+}
+
+// formatNumber prints float without trailing zeros
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// joinPath appends key to dotted path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// validate runs declarative `validate` tag rules and Validator interface on cfg
+func (m *Manager[T]) validate(cfg *T) error {
+	if err := validateTags(cfg, ruleContext{format: m.format, fs: m.fs}); err != nil {
+		return err
+	}
+	if v, ok := any(cfg).(Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
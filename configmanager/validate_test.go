@@ -0,0 +1,133 @@
+package configmanager
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type ruledServer struct {
+	Host string `toml:"host" validate:"required"`
+	Port int    `toml:"port" validate:"min=1,max=65535"`
+}
+
+type ruledConfig struct {
+	Level    string        `toml:"level" validate:"oneof=debug info warn"`
+	Endpoint string        `toml:"endpoint" validate:"url"`
+	DataDir  string        `toml:"data_dir" validate:"path_exists"`
+	Tags     []string      `toml:"tags" validate:"max=2"`
+	Server   ruledServer   `toml:"server"`
+	Backups  []ruledServer `toml:"backups"`
+}
+
+func TestValidateTags(t *testing.T) {
+	valid := ruledConfig{
+		Level:    "info",
+		Endpoint: "https://example.com/api",
+		DataDir:  t.TempDir(),
+		Server:   ruledServer{Host: "localhost", Port: 80},
+	}
+	tests := []struct {
+		name      string
+		modify    func(*ruledConfig)
+		wantPaths []string
+	}{
+		{name: "valid", modify: func(*ruledConfig) {}},
+		{
+			name: "every failure is reported",
+			modify: func(c *ruledConfig) {
+				c.Level = "trace"
+				c.Endpoint = "not a url"
+				c.DataDir = filepath.Join(c.DataDir, "missing")
+				c.Tags = []string{"a", "b", "c"}
+				c.Server = ruledServer{Port: 70000}
+				c.Backups = []ruledServer{{Host: "b", Port: 0}}
+			},
+			wantPaths: []string{"level", "endpoint", "data_dir", "tags", "server.host", "server.port", "backups[0].port"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid
+			tt.modify(&cfg)
+			err := validateTags(&cfg, ruleContext{format: TOML, fs: osFS{}})
+			if len(tt.wantPaths) == 0 {
+				if err != nil {
+					t.Fatalf("validateTags() failed: %v", err)
+				}
+				return
+			}
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("validateTags() error = %v, want *ValidationError", err)
+			}
+			paths := []string{}
+			for _, field := range verr.Fields {
+				paths = append(paths, field.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("failed paths = %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestLoad_ValidateTags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, path, "[server]\nport = 0\n")
+	m, err := New("testapp", ruledConfig{Level: "info"}, ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	err = m.Load()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Load() error = %v, want *ValidationError", err)
+	}
+	if len(verr.Fields) != 2 {
+		t.Errorf("Load() reported %v fields, want 2: %v", len(verr.Fields), err)
+	}
+	if err := m.Save(); err == nil {
+		t.Error("Save() succeeded unexpectedly")
+	}
+}
+
+func TestNew_InvalidRule(t *testing.T) {
+	type badConfig struct {
+		Port int `validate:"between=1"`
+	}
+	if _, err := New("testapp", badConfig{}, ForcePath(filepath.Join(t.TempDir(), "config.toml"))); err == nil {
+		t.Error("New() succeeded unexpectedly")
+	}
+}
+
+func TestValidate_PathExistsOnFS(t *testing.T) {
+	type pathConfig struct {
+		DataDir string `toml:"data_dir" validate:"path_exists"`
+	}
+	fsys := NewMemFS()
+	dir := filepath.Join(t.TempDir(), "memfs")
+	if err := fsys.MkdirAll(filepath.Join(dir, "data"), 0755); err != nil {
+		t.Fatalf("MkdirAll() failed: %v", err)
+	}
+	path := filepath.Join(dir, "config.toml")
+	m, err := New("testapp", pathConfig{}, WithFS(fsys), ForcePath(path))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	for _, tt := range []struct {
+		dataDir string
+		wantErr bool
+	}{
+		{dataDir: filepath.Join(dir, "data")},
+		{dataDir: filepath.Join(dir, "missing"), wantErr: true},
+	} {
+		if err := fsys.WriteFile(path, []byte("data_dir = '"+tt.dataDir+"'\n"), 0600); err != nil {
+			t.Fatalf("WriteFile() failed: %v", err)
+		}
+		if err := m.Load(); (err != nil) != tt.wantErr {
+			t.Errorf("Load() with data_dir %v error = %v, wantErr %v", tt.dataDir, err, tt.wantErr)
+		}
+	}
+}
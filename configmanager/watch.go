@@ -0,0 +1,145 @@
+package configmanager
+
+import (
+	"context"
+	"time"
+)
+
+const defaultWatchInterval = time.Second
+
+// ChangeHandler is called after configuration was reloaded by Watch
+type ChangeHandler[T any] func(old, new T)
+
+// fileState is a snapshot of config file used to detect changes
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// WithWatchInterval option sets how often Watch polls config files for changes
+func WithWatchInterval(interval time.Duration) ManagerOption {
+	return func(mo *managerOptions) {
+		if interval > 0 {
+			mo.watchInterval = interval
+		}
+	}
+}
+
+// Subscribe registers handler which is called with old and new configuration
+// every time Watch reloads it successfully. Returned function removes the handler.
+func (m *Manager[T]) Subscribe(handler ChangeHandler[T]) func() {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	if m.subscribers == nil {
+		m.subscribers = make(map[int]ChangeHandler[T])
+	}
+	id := m.nextSubscriber
+	m.nextSubscriber++
+	m.subscribers[id] = handler
+	return func() {
+		m.subMu.Lock()
+		defer m.subMu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+// Watch polls config files and reloads configuration when any of them changes.
+// If reload or validation fails previous configuration stays live and error is
+// sent to returned channel. Errors are dropped while the channel is full, so
+// callers using only Subscribe need not drain it. Channel is closed when ctx is done.
+func (m *Manager[T]) Watch(ctx context.Context) <-chan error {
+	errs := make(chan error, 1)
+	states := m.snapshot()
+	go func() {
+		defer close(errs)
+		ticker := time.NewTicker(m.watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			current := m.snapshot()
+			if sameStates(states, current) {
+				continue
+			}
+			states = current
+			if err := m.reload(); err != nil {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+		}
+	}()
+	return errs
+}
+
+// reload loads configuration and notifies subscribers on success
+func (m *Manager[T]) reload() error {
+	m.mu.Lock()
+	unlock, err := m.lock(m.schemaVersion > 0)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	old := *m.config
+	err = m.load()
+	current := *m.config
+	unlock()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.subMu.Lock()
+	handlers := make([]ChangeHandler[T], 0, len(m.subscribers))
+	for i := 0; i < m.nextSubscriber; i++ {
+		if handler, ok := m.subscribers[i]; ok {
+			handlers = append(handlers, handler)
+		}
+	}
+	m.subMu.Unlock()
+
+	for _, handler := range handlers {
+		handler(old, current)
+	}
+	return nil
+}
+
+// snapshot returns state of every config file participating in resolution
+func (m *Manager[T]) snapshot() map[string]fileState {
+	m.mu.RLock()
+	paths := []string{m.dropInDir()}
+	for _, layer := range m.layers() {
+		paths = append(paths, layer.path)
+	}
+	paths = append(paths, m.fragments...)
+	m.mu.RUnlock()
+	states := make(map[string]fileState, len(paths))
+	for _, path := range paths {
+		info, err := m.fs.Stat(path)
+		if err != nil {
+			states[path] = fileState{}
+			continue
+		}
+		states[path] = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+	}
+	return states
+}
+
+// sameStates reports whether two snapshots describe the same files
+func sameStates(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		other, ok := b[path]
+		if !ok || other.exists != state.exists || other.size != state.size || !other.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package configmanager
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type validatedConfig struct {
+	Port int `toml:"port"`
+}
+
+func (c *validatedConfig) Validate() error {
+	if c.Port <= 0 {
+		return errors.New("port must be positive")
+	}
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, path, "port = 1\n")
+
+	m, err := New("testapp", validatedConfig{Port: 1}, ForcePath(path), WithWatchInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	changes := make(chan [2]int, 1)
+	unsubscribe := m.Subscribe(func(old, new validatedConfig) {
+		changes <- [2]int{old.Port, new.Port}
+	})
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := m.Watch(ctx)
+
+	writeTestFile(t, path, "port = 200\n")
+	select {
+	case change := <-changes:
+		if change != [2]int{1, 200} {
+			t.Errorf("change = %v, want [1 200]", change)
+		}
+	case err := <-errs:
+		t.Fatalf("Watch() reported error: %v", err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() did not report change")
+	}
+
+	writeTestFile(t, path, "port = -5\n")
+	select {
+	case <-changes:
+		t.Fatal("invalid config was applied")
+	case err := <-errs:
+		if err == nil {
+			t.Fatal("Watch() reported nil error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Watch() did not report validation error")
+	}
+	if got := m.Config().Port; got != 200 {
+		t.Errorf("Config().Port = %v, want 200", got)
+	}
+
+	cancel()
+	for range errs {
+	}
+}
+
+func TestWatch_UndrainedErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeTestFile(t, path, "port = 1\n")
+
+	m, err := New("testapp", validatedConfig{Port: 1}, ForcePath(path), WithWatchInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	if err := m.Load(); err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+	changes := make(chan int, 10)
+	defer m.Subscribe(func(_, new validatedConfig) { changes <- new.Port })()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m.Watch(ctx)
+
+	for _, content := range []string{"port = -5\n", "port = -50\n"} {
+		writeTestFile(t, path, content)
+		time.Sleep(50 * time.Millisecond)
+	}
+	writeTestFile(t, path, "port = 777\n")
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case port := <-changes:
+			if port == 777 {
+				return
+			}
+		case <-timeout:
+			t.Fatal("Watch() stopped reloading after errors were not received")
+		}
+	}
+}
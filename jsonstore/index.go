@@ -0,0 +1,172 @@
+package jsonstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var ErrIndexNotFound = errors.New("index not found")
+var ErrUniqueViolation = errors.New("unique index violation")
+
+// index maps keys computed from records to ids of records. Keys of indexed
+// records are kept, so records changed in place are unindexed correctly.
+type index[T any] struct {
+	name   string
+	key    func(T) string
+	unique bool
+	ids    map[string]map[string]struct{}
+	keys   map[string]string
+}
+
+// AddIndex declares secondary index named name with keys computed by key.
+// Index is built from current records and maintained by Insert, Update and
+// Delete. Records with empty key are not indexed. Unique index rejects records
+// whose key is already used by another record.
+func (db *JsonDB[T]) AddIndex(name string, key func(T) string, unique bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if name == "" || key == nil {
+		return fmt.Errorf("index name and key function must be set")
+	}
+	if _, exists := db.indexes[name]; exists {
+		return fmt.Errorf("index '%v' already exist", name)
+	}
+
+	idx := &index[T]{
+		name:   name,
+		key:    key,
+		unique: unique,
+		ids:    make(map[string]map[string]struct{}),
+		keys:   make(map[string]string),
+	}
+	for id, value := range db.data {
+		k := key(value)
+		if unique && k != "" {
+			for other := range idx.ids[k] {
+				return fmt.Errorf("%w: index '%v' key '%v' is used by records '%v' and '%v'", ErrUniqueViolation, name, k, other, id)
+			}
+		}
+		idx.add(id, k)
+	}
+	if db.indexes == nil {
+		db.indexes = make(map[string]*index[T])
+	}
+	db.indexes[name] = idx
+	return nil
+}
+
+// AddFieldIndex declares secondary index named name keyed by value of exported
+// struct field of records
+func (db *JsonDB[T]) AddFieldIndex(name, field string, unique bool) error {
+	key, err := fieldKey[T](field)
+	if err != nil {
+		return err
+	}
+	return db.AddIndex(name, key, unique)
+}
+
+// FindBy returns records whose key in index equals key
+func (db *JsonDB[T]) FindBy(index, key string) (map[string]T, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	idx, exists := db.indexes[index]
+	if !exists {
+		return nil, fmt.Errorf("%w: %v", ErrIndexNotFound, index)
+	}
+	result := make(map[string]T, len(idx.ids[key]))
+	for id := range idx.ids[key] {
+		result[id] = db.data[id]
+	}
+	return result, nil
+}
+
+// checkUnique returns error if key of value in unique index belongs to other record
+func (db *JsonDB[T]) checkUnique(id string, value T) error {
+	for _, idx := range db.indexes {
+		if !idx.unique {
+			continue
+		}
+		key := idx.key(value)
+		if key == "" {
+			continue
+		}
+		for other := range idx.ids[key] {
+			if other != id {
+				return fmt.Errorf("%w: index '%v' key '%v' is used by record '%v'", ErrUniqueViolation, idx.name, key, other)
+			}
+		}
+	}
+	return nil
+}
+
+// reindex replaces keys of record id with keys of value.
+// Nil value means record was deleted.
+func (db *JsonDB[T]) reindex(id string, value *T) {
+	for _, idx := range db.indexes {
+		idx.remove(id)
+		if value != nil {
+			idx.add(id, idx.key(*value))
+		}
+	}
+}
+
+func (idx *index[T]) add(id, key string) {
+	if key == "" {
+		return
+	}
+	if idx.ids[key] == nil {
+		idx.ids[key] = make(map[string]struct{})
+	}
+	idx.ids[key][id] = struct{}{}
+	idx.keys[id] = key
+}
+
+func (idx *index[T]) remove(id string) {
+	key, exists := idx.keys[id]
+	if !exists {
+		return
+	}
+	delete(idx.ids[key], id)
+	if len(idx.ids[key]) == 0 {
+		delete(idx.ids, key)
+	}
+	delete(idx.keys, id)
+}
+
+// fieldKey returns function formatting value of struct field of T
+func fieldKey[T any](field string) (func(T) string, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("records of type %v have no fields", t)
+	}
+	sf, ok := t.FieldByName(field)
+	if !ok || !sf.IsExported() {
+		return nil, fmt.Errorf("type %v has no exported field '%v'", t, field)
+	}
+	return func(value T) string {
+		v := reflect.ValueOf(value)
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return ""
+			}
+			v = v.Elem()
+		}
+		f, err := v.FieldByIndexErr(sf.Index)
+		if err != nil {
+			return ""
+		}
+		for f.Kind() == reflect.Pointer || f.Kind() == reflect.Interface {
+			if f.IsNil() {
+				return ""
+			}
+			f = f.Elem()
+		}
+		return fmt.Sprint(f.Interface())
+	}, nil
+}
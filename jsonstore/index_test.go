@@ -0,0 +1,194 @@
+package jsonstore
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestJsonDB_FindBy(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		setup   func(*JsonDB[TestData]) error
+		index   string
+		key     string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "find after insert",
+			setup: func(db *JsonDB[TestData]) error {
+				db.Insert("a", TestData{Name: "x", Value: 1})
+				db.Insert("b", TestData{Name: "x", Value: 2})
+				db.Insert("c", TestData{Name: "y", Value: 3})
+				return nil
+			},
+			index: "name",
+			key:   "x",
+			want:  []string{"a", "b"},
+		},
+		{
+			name: "find after update",
+			setup: func(db *JsonDB[TestData]) error {
+				db.Insert("a", TestData{Name: "x", Value: 1})
+				return db.Update("a", TestData{Name: "y", Value: 1})
+			},
+			index: "name",
+			key:   "y",
+			want:  []string{"a"},
+		},
+		{
+			name: "old key is removed on update",
+			setup: func(db *JsonDB[TestData]) error {
+				db.Insert("a", TestData{Name: "x", Value: 1})
+				return db.Update("a", TestData{Name: "y", Value: 1})
+			},
+			index: "name",
+			key:   "x",
+			want:  []string{},
+		},
+		{
+			name: "find after delete",
+			setup: func(db *JsonDB[TestData]) error {
+				db.Insert("a", TestData{Name: "x", Value: 1})
+				db.Insert("b", TestData{Name: "x", Value: 2})
+				return db.Delete("a")
+			},
+			index: "name",
+			key:   "x",
+			want:  []string{"b"},
+		},
+		{
+			name: "find by integer field",
+			setup: func(db *JsonDB[TestData]) error {
+				db.Insert("a", TestData{Name: "x", Value: 7})
+				return nil
+			},
+			index: "value",
+			key:   "7",
+			want:  []string{"a"},
+		},
+		{
+			name:    "unknown index",
+			setup:   func(db *JsonDB[TestData]) error { return nil },
+			index:   "missing",
+			key:     "x",
+			wantErr: ErrIndexNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := New[TestData](filepath.Join(tmpDir, tt.name+".json"))
+			if err != nil {
+				t.Fatalf("New() failed: %v", err)
+			}
+			if err := db.AddFieldIndex("name", "Name", false); err != nil {
+				t.Fatalf("AddFieldIndex() failed: %v", err)
+			}
+			if err := db.AddFieldIndex("value", "Value", false); err != nil {
+				t.Fatalf("AddFieldIndex() failed: %v", err)
+			}
+			if err := tt.setup(db); err != nil {
+				t.Fatalf("setup failed: %v", err)
+			}
+
+			got, err := db.FindBy(tt.index, tt.key)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("FindBy() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBy() failed: %v", err)
+			}
+			ids := make([]string, 0, len(got))
+			for id := range got {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			if len(ids) != len(tt.want) {
+				t.Fatalf("FindBy() = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Errorf("FindBy() = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestJsonDB_UniqueIndex(t *testing.T) {
+	db, err := New[TestData](filepath.Join(t.TempDir(), "unique.json"))
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	byName := func(d TestData) string { return d.Name }
+	if err := db.AddIndex("name", byName, true); err != nil {
+		t.Fatalf("AddIndex() failed: %v", err)
+	}
+	if err := db.AddIndex("name", byName, false); err == nil {
+		t.Error("AddIndex() with duplicate name succeeded")
+	}
+
+	if err := db.Insert("a", TestData{Name: "x", Value: 1}); err != nil {
+		t.Fatalf("Insert() failed: %v", err)
+	}
+	if err := db.Insert("b", TestData{Name: "x", Value: 2}); !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("Insert() error = %v, want %v", err, ErrUniqueViolation)
+	}
+	if db.Contains("b") {
+		t.Error("rejected record was inserted")
+	}
+	if err := db.Insert("b", TestData{Name: "y", Value: 2}); err != nil {
+		t.Fatalf("Insert() failed: %v", err)
+	}
+	if err := db.Update("b", TestData{Name: "x", Value: 2}); !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("Update() error = %v, want %v", err, ErrUniqueViolation)
+	}
+	if got, _ := db.Get("b"); got.Name != "y" {
+		t.Errorf("rejected update changed record: %+v", got)
+	}
+	if err := db.Update("a", TestData{Name: "x", Value: 10}); err != nil {
+		t.Errorf("Update() keeping own key failed: %v", err)
+	}
+	if err := db.Delete("a"); err != nil {
+		t.Fatalf("Delete() failed: %v", err)
+	}
+	if err := db.Update("b", TestData{Name: "x", Value: 2}); err != nil {
+		t.Errorf("Update() to released key failed: %v", err)
+	}
+}
+
+func TestJsonDB_AddIndexExistingData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "existing.json")
+	if err := os.WriteFile(path, []byte(`{"a":{"name":"x","value":1},"b":{"name":"x","value":2}}`), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	db, err := Load[TestData](path)
+	if err != nil {
+		t.Fatalf("Load() failed: %v", err)
+	}
+
+	if err := db.AddFieldIndex("unique", "Name", true); !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("AddFieldIndex() error = %v, want %v", err, ErrUniqueViolation)
+	}
+	if _, err := db.FindBy("unique", "x"); !errors.Is(err, ErrIndexNotFound) {
+		t.Errorf("failed index was registered: %v", err)
+	}
+	if err := db.AddFieldIndex("bad", "Missing", false); err == nil {
+		t.Error("AddFieldIndex() with unknown field succeeded")
+	}
+	if err := db.AddFieldIndex("name", "Name", false); err != nil {
+		t.Fatalf("AddFieldIndex() failed: %v", err)
+	}
+	got, err := db.FindBy("name", "x")
+	if err != nil || len(got) != 2 {
+		t.Errorf("FindBy() = %v, %v, want 2 records", got, err)
+	}
+}
@@ -32,6 +32,7 @@ type JsonDB[T any] struct {
 	marshalingMethod MarshalingMethod
 	prefix           string
 	indent           string
+	indexes          map[string]*index[T]
 }
 
 type options struct {
@@ -231,13 +232,18 @@ func (db *JsonDB[T]) Insert(id string, value T) error {
 	if _, exists := db.data[id]; exists {
 		return ErrRecordExist
 	}
+	if err := db.checkUnique(id, value); err != nil {
+		return err
+	}
 
 	oldData := maps.Clone(db.data)
 
 	db.data[id] = value
+	db.reindex(id, &value)
 	if db.autoSave {
 		if err := db.internalSave(); err != nil {
 			db.data = oldData
+			db.reindex(id, nil)
 			return fmt.Errorf("failed to save db: %v", err)
 		}
 	}
@@ -282,16 +288,22 @@ func (db *JsonDB[T]) Update(id string, value T) error {
 	if id == "" {
 		return fmt.Errorf("empty entry id")
 	}
-	if _, exists := db.data[id]; !exists {
+	old, exists := db.data[id]
+	if !exists {
 		return ErrRecordNotFound
 	}
+	if err := db.checkUnique(id, value); err != nil {
+		return err
+	}
 
 	oldData := maps.Clone(db.data)
 
 	db.data[id] = value
+	db.reindex(id, &value)
 	if db.autoSave {
 		if err := db.internalSave(); err != nil {
 			db.data = oldData
+			db.reindex(id, &old)
 			return fmt.Errorf("failed to save db: %v", err)
 		}
 	}
@@ -304,16 +316,19 @@ func (db *JsonDB[T]) Delete(id string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if _, exists := db.data[id]; !exists {
+	old, exists := db.data[id]
+	if !exists {
 		return ErrRecordNotFound
 	}
 
 	oldData := maps.Clone(db.data)
 
 	delete(db.data, id)
+	db.reindex(id, nil)
 	if db.autoSave {
 		if err := db.internalSave(); err != nil {
 			db.data = oldData
+			db.reindex(id, &old)
 			return fmt.Errorf("failed to save db: %v", err)
 		}
 	}
@@ -133,6 +133,27 @@ func ForTemp() PathOption {
 	return WithBaseDir("temp")
 }
 
+// BaseDir returns base directory of given type (config, data, cache, state,
+// runtime or temp). Returns an empty string for unknown type.
+func BaseDir(dirType string) string {
+	return getBaseDir(dirType)
+}
+
+// ConfigDirs returns system config directories from XDG_CONFIG_DIRS in order
+// of preference. Defaults to /etc/xdg if the variable is not set.
+func ConfigDirs() []string {
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(os.Getenv("XDG_CONFIG_DIRS")) {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		dirs = append(dirs, filepath.Join(string(filepath.Separator), "etc", "xdg"))
+	}
+	return dirs
+}
+
 // runtimeHome returns the path to the runtime directory.
 func runtimeHome() string {
 	if path := os.Getenv("XDG_RUNTIME_DIR"); path != "" {